@@ -0,0 +1,113 @@
+package imagorvideo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+	"github.com/cshum/vipsgen/vips"
+)
+
+const (
+	maxBIFFrames       = 1000
+	defaultBIFInterval = 10 * time.Second
+	bifWidth           = 320
+	bifHeaderSize      = 64
+	bifIndexEntryEnd   = 0xffffffff
+)
+
+var bifMagic = []byte{0x89, 'B', 'I', 'F', 0x0d, 0x0a, 0x1a, 0x0a}
+
+// bifDurations sample points of BIF frames every interval from start until end.
+// Interval is widened to whole milliseconds that fit the range within maxBIFFrames
+func bifDurations(start, end, interval time.Duration) ([]time.Duration, time.Duration) {
+	n := 1
+	if end > start {
+		if n = int((end - start + interval - 1) / interval); n > maxBIFFrames {
+			interval = (end - start + maxBIFFrames - 1) / maxBIFFrames
+			interval = (interval + time.Millisecond - 1).Truncate(time.Millisecond)
+			n = int((end - start + interval - 1) / interval)
+		}
+	}
+	return sampleDurations(n, start, end, interval), interval
+}
+
+// newBIFBlob exports frames every interval from start until end as JPEG
+// and packs them into Roku BIF trickplay archive
+func newBIFBlob(
+	av *ffmpeg.AVContext, meta *ffmpeg.Metadata, params imagorpath.Params, start, end, interval time.Duration,
+) (*imagor.Blob, error) {
+	if interval < time.Millisecond {
+		interval = defaultBIFInterval
+	}
+	durations, interval := bifDurations(start, end, interval)
+	width, height := params.Width, params.Height
+	if width == 0 && height == 0 {
+		width = bifWidth
+	}
+	frameWidth, frameHeight := meta.FrameSize()
+	frames := make([][]byte, len(durations))
+	err := av.ExportFramesFunc(durations, 3, func(i int, buf []byte) error {
+		buf, w, h := orientBuffer(buf, frameWidth, frameHeight, 3, meta.Orientation)
+		img, err := vips.NewImageFromMemory(buf, w, h, 3)
+		if err != nil {
+			return err
+		}
+		defer img.Close()
+		scale := 1.0
+		if width > 0 {
+			scale = math.Min(scale, float64(width)/float64(w))
+		}
+		if height > 0 {
+			scale = math.Min(scale, float64(height)/float64(h))
+		}
+		if scale < 1 {
+			if err = img.Resize(scale, nil); err != nil {
+				return err
+			}
+		}
+		frames[i], err = img.JpegsaveBuffer(nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	blob := imagor.NewBlobFromBytes(encodeBIF(frames, durations, interval))
+	blob.SetContentType("application/octet-stream")
+	return blob, nil
+}
+
+// encodeBIF encodes JPEG frames into BIF archive,
+// with interval as the timestamp multiplier of index entries
+func encodeBIF(frames [][]byte, durations []time.Duration, interval time.Duration) []byte {
+	separation := uint32(interval / time.Millisecond)
+	var buf bytes.Buffer
+	header := make([]byte, bifHeaderSize)
+	copy(header, bifMagic)
+	binary.LittleEndian.PutUint32(header[8:], 0) // version
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(frames)))
+	binary.LittleEndian.PutUint32(header[16:], separation)
+	buf.Write(header)
+
+	offset := uint32(bifHeaderSize + (len(frames)+1)*8)
+	entry := make([]byte, 8)
+	for i, frame := range frames {
+		ms := uint32(durations[i] / time.Millisecond)
+		binary.LittleEndian.PutUint32(entry, (ms+separation/2)/separation)
+		binary.LittleEndian.PutUint32(entry[4:], offset)
+		buf.Write(entry)
+		offset += uint32(len(frame))
+	}
+	binary.LittleEndian.PutUint32(entry, bifIndexEntryEnd)
+	binary.LittleEndian.PutUint32(entry[4:], offset)
+	buf.Write(entry)
+
+	for _, frame := range frames {
+		buf.Write(frame)
+	}
+	return buf.Bytes()
+}
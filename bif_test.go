@@ -0,0 +1,43 @@
+package imagorvideo
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeBIF(t *testing.T) {
+	frames := [][]byte{[]byte("foo"), []byte("barbaz")}
+	durations, interval := bifDurations(0, 15*time.Second, 10*time.Second)
+	assert.Equal(t, []time.Duration{0, 10 * time.Second}, durations)
+	assert.Equal(t, 10*time.Second, interval)
+	buf := encodeBIF(frames, durations, 10*time.Second)
+	assert.Equal(t, bifMagic, buf[:8])
+	assert.Equal(t, uint32(0), binary.LittleEndian.Uint32(buf[8:]))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(buf[12:]))
+	assert.Equal(t, uint32(10000), binary.LittleEndian.Uint32(buf[16:]))
+	index := buf[64:]
+	assert.Equal(t, []uint32{0, 88, 1, 91, 0xffffffff, 97}, []uint32{
+		binary.LittleEndian.Uint32(index[0:]), binary.LittleEndian.Uint32(index[4:]),
+		binary.LittleEndian.Uint32(index[8:]), binary.LittleEndian.Uint32(index[12:]),
+		binary.LittleEndian.Uint32(index[16:]), binary.LittleEndian.Uint32(index[20:]),
+	})
+	assert.Equal(t, "foobarbaz", string(buf[88:]))
+	assert.Len(t, buf, 97)
+}
+
+func TestBIFDurationsWidenInterval(t *testing.T) {
+	end := 3*time.Hour + 7*time.Millisecond
+	durations, interval := bifDurations(0, end, time.Second)
+	assert.Equal(t, 10801*time.Millisecond, interval)
+	assert.Len(t, durations, maxBIFFrames)
+	assert.Less(t, durations[len(durations)-1], end)
+	assert.GreaterOrEqual(t, durations[len(durations)-1]+interval, end)
+
+	durations, interval = bifDurations(time.Hour, 2*time.Hour, 100*time.Millisecond)
+	assert.Equal(t, 3600*time.Millisecond, interval)
+	assert.Len(t, durations, maxBIFFrames)
+	assert.Equal(t, time.Hour, durations[0])
+}
@@ -1,8 +1,15 @@
 package imagorvideo
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"flag"
+	"strconv"
+	"strings"
+
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
 	"go.uber.org/zap"
 )
 
@@ -11,14 +18,41 @@ func Config(fs *flag.FlagSet, cb func() (*zap.Logger, bool)) imagor.Option {
 	var (
 		ffmpegFallbackImage = fs.String("ffmpeg-fallback-image", "",
 			"FFmpeg fallback image on processing error. Supports image path enabled by loaders or storages")
+		ffmpegFastDecode = fs.Bool("ffmpeg-fast-decode", false,
+			"FFmpeg decodes keyframes only and skips loop filtering by default for faster processing. Can be overridden by fast(false) filter")
 
 		logger, isDebug = cb()
 	)
 	return imagor.WithProcessors(
 		NewProcessor(
 			WithFallbackImage(*ffmpegFallbackImage),
+			WithFastDecode(*ffmpegFastDecode),
+			WithSigner(imagorSigner(fs)),
 			WithLogger(logger),
 			WithDebug(isDebug),
 		),
 	)
 }
+
+// imagorSigner URL signer of the imagor-secret flag if defined,
+// matching the signer type and truncate of imagor
+func imagorSigner(fs *flag.FlagSet) imagorpath.Signer {
+	secret := fs.Lookup("imagor-secret")
+	if secret == nil || secret.Value.String() == "" {
+		return nil
+	}
+	alg := sha1.New
+	if f := fs.Lookup("imagor-signer-type"); f != nil {
+		switch strings.ToLower(f.Value.String()) {
+		case "sha256":
+			alg = sha256.New
+		case "sha512":
+			alg = sha512.New
+		}
+	}
+	var truncate int
+	if f := fs.Lookup("imagor-signer-truncate"); f != nil {
+		truncate, _ = strconv.Atoi(f.Value.String())
+	}
+	return imagorpath.NewHMACSigner(alg, truncate, secret.Value.String())
+}
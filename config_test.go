@@ -4,14 +4,30 @@ import (
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
 func TestConfig(t *testing.T) {
 	srv := config.CreateServer([]string{
 		"-ffmpeg-fallback-image", "https://foo.com/bar.jpg",
+		"-ffmpeg-fast-decode",
 	}, Config)
 	app := srv.App.(*imagor.Imagor)
 	processor := app.Processors[0].(*Processor)
 	assert.Equal(t, "https://foo.com/bar.jpg", processor.FallbackImage)
+	assert.True(t, processor.FastDecode)
+	assert.Nil(t, processor.Signer)
+}
+
+func TestConfigSigner(t *testing.T) {
+	srv := config.CreateServer([]string{
+		"-imagor-secret", "1234",
+		"-imagor-signer-type", "sha256",
+		"-imagor-signer-truncate", "40",
+	}, Config)
+	app := srv.App.(*imagor.Imagor)
+	processor := app.Processors[0].(*Processor)
+	require.NotNil(t, processor.Signer)
+	assert.Equal(t, app.Signer.Sign("filters:sprite(3,1)/foo.mp4"), processor.Signer.Sign("filters:sprite(3,1)/foo.mp4"))
 }
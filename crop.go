@@ -0,0 +1,25 @@
+package imagorvideo
+
+import (
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+)
+
+// cropParams sets imagor crop from the detected active picture rectangle.
+// Rectangle is rotated as imagor applies the orient filter before crop,
+// whereas flips of mirrored orientations are applied after crop
+func cropParams(params imagorpath.Params, meta *ffmpeg.Metadata) (imagorpath.Params, bool) {
+	c := meta.Crop
+	width, height := meta.FrameSize()
+	if c == nil || (c.Left == 0 && c.Top == 0 && c.Right == width && c.Bottom == height) {
+		return params, false
+	}
+	rotation, _, _ := orientFlips(meta.Orientation)
+	x1, y1 := orientPoint(c.Left, c.Top, width, height, rotation)
+	x2, y2 := orientPoint(c.Right-1, c.Bottom-1, width, height, rotation)
+	params.CropLeft = float64(min(x1, x2))
+	params.CropTop = float64(min(y1, y2))
+	params.CropRight = float64(max(x1, x2) + 1)
+	params.CropBottom = float64(max(y1, y2) + 1)
+	return params, true
+}
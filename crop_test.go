@@ -0,0 +1,48 @@
+package imagorvideo
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCropParams(t *testing.T) {
+	// 1920x1080 frame with 4:3 picture pillarboxed
+	crop := &ffmpeg.Crop{Left: 240, Top: 0, Right: 1680, Bottom: 1080}
+	tests := []struct {
+		name        string
+		orientation int
+		crop        *ffmpeg.Crop
+		expected    [4]float64
+		ok          bool
+	}{
+		{name: "no crop"},
+		{name: "full frame", crop: &ffmpeg.Crop{Right: 1920, Bottom: 1080}},
+		{name: "pillarbox", orientation: 1, crop: crop,
+			expected: [4]float64{240, 0, 1680, 1080}, ok: true},
+		{name: "pillarbox orient 180", orientation: 3, crop: crop,
+			expected: [4]float64{240, 0, 1680, 1080}, ok: true},
+		{name: "pillarbox orient 90", orientation: 6, crop: crop,
+			expected: [4]float64{0, 240, 1080, 1680}, ok: true},
+		{name: "pillarbox mirrored", orientation: 2,
+			crop:     &ffmpeg.Crop{Left: 200, Top: 0, Right: 1680, Bottom: 1080},
+			expected: [4]float64{200, 0, 1680, 1080}, ok: true},
+		{name: "pillarbox transpose", orientation: 5,
+			crop:     &ffmpeg.Crop{Left: 200, Top: 0, Right: 1680, Bottom: 1080},
+			expected: [4]float64{0, 200, 1080, 1680}, ok: true},
+		{name: "letterbox orient 270", orientation: 8,
+			crop:     &ffmpeg.Crop{Left: 0, Top: 140, Right: 1920, Bottom: 1000},
+			expected: [4]float64{140, 0, 1000, 1920}, ok: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := orientedMeta(1920, 1080, tt.orientation)
+			meta.Crop = tt.crop
+			params, ok := cropParams(imagorpath.Params{}, meta)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, [4]float64{params.CropLeft, params.CropTop, params.CropRight, params.CropBottom})
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package imagorvideo
+
+import (
+	"github.com/cshum/imagor"
+	"github.com/cshum/vipsgen/vips"
+)
+
+// newHighDepthBlob encodes 16-bit RGB or RGBA frame as 16-bit PNG blob,
+// since imagor memory blob holds 8-bit pixels only
+func newHighDepthBlob(buf []byte, width, height, bands int) (*imagor.Blob, error) {
+	// load as 8-bit image of double width, then reinterpret as 16-bit
+	raw, err := vips.NewImageFromMemory(buf, width*2, height, bands)
+	if err != nil {
+		return nil, err
+	}
+	defer raw.Close()
+	img, err := raw.Copy(&vips.CopyOptions{
+		Width:          width,
+		Format:         vips.BandFormatUshort,
+		Interpretation: vips.InterpretationRgb16,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	opts := vips.DefaultPngsaveBufferOptions()
+	opts.Bitdepth = 16
+	opts.Compression = 1
+	buf, err = img.PngsaveBuffer(opts)
+	if err != nil {
+		return nil, err
+	}
+	return imagor.NewBlobFromBytes(buf), nil
+}
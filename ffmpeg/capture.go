@@ -0,0 +1,107 @@
+package ffmpeg
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location capture location of ISO 6709 location tag, in degrees and meters
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"`
+}
+
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)?`)
+
+// creationTimeLayouts layouts of creation time tags, such as creation_time of MP4 and MOV,
+// and com.apple.quicktime.creationdate in local time with offset
+var creationTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// captureTags populates the creation time, device and location of metadata from the capture tags
+func captureTags(av *AVContext, tags map[string]string, meta *Metadata) {
+	creationTime := firstTag(tags, "com.apple.quicktime.creationdate", "creation_time")
+	if creationTime == "" && av.stream != nil {
+		creationTime = dictEntries(av.stream.metadata)["creation_time"]
+	}
+	meta.CreationTime = parseCreationTime(creationTime)
+	meta.Make = firstTag(tags, "com.apple.quicktime.make", "com.android.manufacturer", "make")
+	meta.Model = firstTag(tags, "com.apple.quicktime.model", "com.android.model", "model")
+	meta.Location = parseISO6709(firstTag(tags,
+		"com.apple.quicktime.location.iso6709", "location", "location-eng"))
+}
+
+// firstTag value of the first tag present, with all the keys removed from tags
+func firstTag(tags map[string]string, keys ...string) (value string) {
+	for _, key := range keys {
+		if v := popTag(tags, key); value == "" {
+			value = v
+		}
+	}
+	return
+}
+
+// parseCreationTime creation time in RFC3339, empty if not parsable
+func parseCreationTime(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ""
+	}
+	for _, layout := range creationTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// parseISO6709 location of ISO 6709 string such as +37.7749-122.4194+010.000/,
+// in decimal degrees, or degrees and minutes and seconds such as +3746.494-12225.164/
+func parseISO6709(s string) *Location {
+	m := iso6709.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil
+	}
+	loc := &Location{
+		Latitude:  iso6709Degrees(m[1], 2),
+		Longitude: iso6709Degrees(m[2], 3),
+	}
+	if m[3] != "" {
+		loc.Altitude, _ = strconv.ParseFloat(m[3], 64)
+	}
+	if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+		return nil
+	}
+	return loc
+}
+
+// iso6709Degrees decimal degrees of ISO 6709 coordinate,
+// by the number of integer digits of degrees, minutes and seconds
+func iso6709Degrees(s string, degreeDigits int) float64 {
+	sign := 1.0
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = s[1:]
+	whole, _, _ := strings.Cut(s, ".")
+	v, _ := strconv.ParseFloat(s, 64)
+	switch len(whole) - degreeDigits {
+	case 2:
+		// DDMM.MMM
+		deg := float64(int(v / 100))
+		v = deg + (v-deg*100)/60
+	case 4:
+		// DDMMSS.SSS
+		deg := float64(int(v / 10000))
+		minutes := float64(int(v/100)) - deg*100
+		v = deg + minutes/60 + (v-deg*10000-minutes*100)/3600
+	}
+	return sign * v
+}
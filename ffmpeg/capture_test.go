@@ -0,0 +1,63 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseISO6709(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected *Location
+	}{
+		{in: "+37.7749-122.4194+010.000/", expected: &Location{Latitude: 37.7749, Longitude: -122.4194, Altitude: 10}},
+		{in: "-33.8688+151.2093/", expected: &Location{Latitude: -33.8688, Longitude: 151.2093}},
+		{in: "+3746.494-12225.164/", expected: &Location{Latitude: 37.7749, Longitude: -122.4194}},
+		{in: "+374629.64-1222509.84/", expected: &Location{Latitude: 37.7749, Longitude: -122.4194}},
+		{in: "+95.0000+000.0000/"},
+		{in: "garbage"},
+		{in: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			loc := parseISO6709(tt.in)
+			if tt.expected == nil {
+				assert.Nil(t, loc)
+				return
+			}
+			if assert.NotNil(t, loc) {
+				assert.InDelta(t, tt.expected.Latitude, loc.Latitude, 1e-6)
+				assert.InDelta(t, tt.expected.Longitude, loc.Longitude, 1e-6)
+				assert.InDelta(t, tt.expected.Altitude, loc.Altitude, 1e-6)
+			}
+		})
+	}
+}
+
+func TestParseCreationTime(t *testing.T) {
+	assert.Equal(t, "2023-05-01T12:34:56Z", parseCreationTime("2023-05-01T12:34:56.000000Z"))
+	assert.Equal(t, "2023-05-01T14:34:56+02:00", parseCreationTime("2023-05-01T14:34:56+0200"))
+	assert.Equal(t, "2019-06-15T12:00:00Z", parseCreationTime("2019-06-15 12:00:00"))
+	assert.Empty(t, parseCreationTime("yesterday"))
+	assert.Empty(t, parseCreationTime(""))
+}
+
+func TestCaptureTags(t *testing.T) {
+	tags := map[string]string{
+		"creation_time":                    "2023-05-01T12:34:56.000000Z",
+		"com.apple.quicktime.creationdate": "2023-05-01T14:34:56+0200",
+		"com.apple.quicktime.make":         "Apple",
+		"com.apple.quicktime.model":        "iPhone 14 Pro",
+		"location":                         "+37.7749-122.4194+010.000/",
+		"location-eng":                     "+37.7749-122.4194+010.000/",
+		"encoder":                          "Lavf60.3.100",
+	}
+	meta := &Metadata{}
+	captureTags(&AVContext{}, tags, meta)
+	assert.Equal(t, "2023-05-01T14:34:56+02:00", meta.CreationTime)
+	assert.Equal(t, "Apple", meta.Make)
+	assert.Equal(t, "iPhone 14 Pro", meta.Model)
+	assert.Equal(t, &Location{Latitude: 37.7749, Longitude: -122.4194, Altitude: 10}, meta.Location)
+	assert.Equal(t, map[string]string{"encoder": "Lavf60.3.100"}, tags)
+}
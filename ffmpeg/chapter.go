@@ -0,0 +1,44 @@
+package ffmpeg
+
+// #include "ffmpeg.h"
+import "C"
+import (
+	"time"
+	"unsafe"
+)
+
+// Chapter chapter of the media file, start and end in milliseconds
+type Chapter struct {
+	ID    int64  `json:"id"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Title string `json:"title,omitempty"`
+}
+
+// formatChapters all chapters of the format context
+func formatChapters(av *AVContext) []*C.AVChapter {
+	if av.closed || av.formatContext == nil || av.formatContext.nb_chapters == 0 {
+		return nil
+	}
+	return unsafe.Slice(av.formatContext.chapters, av.formatContext.nb_chapters)
+}
+
+// chapters chapters of the media file
+func chapters(av *AVContext) (list []Chapter) {
+	for _, ch := range formatChapters(av) {
+		start, end := chapterDurations(ch)
+		list = append(list, Chapter{
+			ID:    int64(ch.id),
+			Start: int(start / time.Millisecond),
+			End:   int(end / time.Millisecond),
+			Title: dictEntries(ch.metadata)["title"],
+		})
+	}
+	return
+}
+
+// chapterDurations start and end of chapter
+func chapterDurations(ch *C.AVChapter) (start, end time.Duration) {
+	tb := rationalToFloat(ch.time_base) * float64(time.Second)
+	return time.Duration(float64(ch.start) * tb), time.Duration(float64(ch.end) * tb)
+}
@@ -13,6 +13,9 @@ const (
 	ErrDecoderNotFound = avError(C.AVERROR_DECODER_NOT_FOUND)
 	ErrInvalidData     = avError(C.AVERROR_INVALIDDATA)
 	ErrTooBig          = avError(C.ERR_TOO_BIG)
+	ErrFrameRejected   = avError(C.ERR_REJECTED)
+	ErrStreamNotFound  = avError(C.AVERROR_STREAM_NOT_FOUND)
+	ErrChapterNotFound = avError(C.ERR_CHAPTER_NOT_FOUND)
 )
 
 func (e avError) errorString() string {
@@ -21,12 +24,18 @@ func (e avError) errorString() string {
 		return "cannot allocate memory"
 	case ErrTooBig:
 		return "video or cover art size exceeds maximum allowed dimensions"
+	case ErrFrameRejected:
+		return "all candidate frames rejected by frame scorer"
 	case ErrEOF:
 		return "end of file"
 	case ErrDecoderNotFound:
 		return "decoder not found"
 	case ErrInvalidData:
 		return "invalid data found when processing input"
+	case ErrStreamNotFound:
+		return "stream not found"
+	case ErrChapterNotFound:
+		return "chapter not found"
 	default:
 		return "unknown error occurred"
 	}
@@ -71,6 +71,8 @@ int create_format_context(AVFormatContext *fmt_ctx, void* opaque, int flags) {
 
 static int get_orientation(AVStream *video_stream) {
     const AVPacketSideData *side_data = NULL;
+    int32_t matrix[9];
+    int hflip = 0;
     double theta = 0;
 
     // Use the new API to get side data from codecpar
@@ -79,7 +81,13 @@ static int get_orientation(AVStream *video_stream) {
                                        AV_PKT_DATA_DISPLAYMATRIX);
 
     if (side_data && side_data->size >= 9 * sizeof(int32_t)) {
-        theta = -av_display_rotation_get((int32_t *) side_data->data);
+        memcpy(matrix, side_data->data, sizeof matrix);
+        // mirrored matrix of negative determinant, as rotation followed by horizontal flip
+        if ((double) matrix[0] * matrix[4] - (double) matrix[1] * matrix[3] < 0) {
+            av_display_matrix_flip(matrix, 1, 0);
+            hflip = 1;
+        }
+        theta = -av_display_rotation_get(matrix);
     }
 
     theta -= 360 * floor(theta / 360 + 0.9 / 360);
@@ -88,13 +96,13 @@ static int get_orientation(AVStream *video_stream) {
 
     switch (rot) {
         case 90:
-            return 6;
+            return hflip ? 5 : 6;
         case 180:
-            return 3;
+            return hflip ? 4 : 3;
         case 270:
-            return 8;
+            return hflip ? 7 : 8;
         default:
-            return 1;
+            return hflip ? 2 : 1;
     };
 }
 
@@ -108,8 +116,8 @@ void get_metadata(AVFormatContext *fmt_ctx, char **artist, char **title) {
     }
 }
 
-int find_streams(AVFormatContext *fmt_ctx, AVStream **video_stream, int *orientation) {
-    int video_stream_index = av_find_best_stream(fmt_ctx, AVMEDIA_TYPE_VIDEO, -1, -1, NULL, 0);
+int find_streams(AVFormatContext *fmt_ctx, AVStream **video_stream, int *orientation, int wanted_stream) {
+    int video_stream_index = av_find_best_stream(fmt_ctx, AVMEDIA_TYPE_VIDEO, wanted_stream, -1, NULL, 0);
     int audio_stream_index = av_find_best_stream(fmt_ctx, AVMEDIA_TYPE_AUDIO, -1, -1, NULL, 0);
     int video_audio = 0;
     if (audio_stream_index >= 0) {
@@ -118,7 +126,7 @@ int find_streams(AVFormatContext *fmt_ctx, AVStream **video_stream, int *orienta
     if (video_stream_index >= 0) {
         video_audio |= HAS_VIDEO_STREAM;
     } else {
-        if (video_audio) {
+        if (video_audio && wanted_stream < 0) {
             return video_audio;
         }
         return AVERROR_STREAM_NOT_FOUND;
@@ -141,7 +149,21 @@ static int open_codec(AVCodecContext *codec_ctx, const AVCodec *codec) {
     return err;
 }
 
-int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx) {
+static int find_lowres(const AVCodec *dec, AVCodecParameters *par, int width, int height) {
+    // largest decoder downscale factor that keeps frame no smaller than width x height
+    int lowres = 0;
+    if (width <= 0 || height <= 0) {
+        return lowres;
+    }
+    while (lowres < dec->max_lowres &&
+           AV_CEIL_RSHIFT(par->width, lowres + 1) >= width &&
+           AV_CEIL_RSHIFT(par->height, lowres + 1) >= height) {
+        lowres++;
+    }
+    return lowres;
+}
+
+int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx, int width, int height) {
     const AVCodec *dec = NULL;
     AVCodecParameters *par = video_stream->codecpar;
     if (par->codec_id == AV_CODEC_ID_VP8) {
@@ -169,6 +191,7 @@ int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx) {
         avcodec_free_context(dec_ctx);
         return err;
     }
+    (*dec_ctx)->lowres = find_lowres(dec, par, width, height);
     err = open_codec(*dec_ctx, dec);
     if (err < 0) {
         avcodec_free_context(dec_ctx);
@@ -176,31 +199,84 @@ int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx) {
     return err;
 }
 
-AVFrame *convert_frame_to_rgb(AVFrame *frame, int alpha) {
-    int output_fmt = alpha ? AV_PIX_FMT_RGBA : AV_PIX_FMT_RGB24;
+static int is_rgb_format(int format) {
+    const AVPixFmtDescriptor *desc = av_pix_fmt_desc_get(format);
+    return desc && (desc->flags & AV_PIX_FMT_FLAG_RGB);
+}
+
+static int is_full_range(const AVFrame *frame) {
+    switch (frame->format) {
+        case AV_PIX_FMT_YUVJ420P:
+        case AV_PIX_FMT_YUVJ422P:
+        case AV_PIX_FMT_YUVJ444P:
+            return 1;
+        default:
+            return frame->color_range == AVCOL_RANGE_JPEG;
+    }
+}
+
+static int sws_colorspace(const AVFrame *frame) {
+    // YUV matrix of the frame, guessed from resolution if unspecified as of ffmpeg
+    switch (frame->colorspace) {
+        case AVCOL_SPC_BT709:
+            return SWS_CS_ITU709;
+        case AVCOL_SPC_FCC:
+            return SWS_CS_FCC;
+        case AVCOL_SPC_SMPTE240M:
+            return SWS_CS_SMPTE240M;
+        case AVCOL_SPC_BT2020_NCL:
+        case AVCOL_SPC_BT2020_CL:
+            return SWS_CS_BT2020;
+        case AVCOL_SPC_BT470BG:
+        case AVCOL_SPC_SMPTE170M:
+            return SWS_CS_ITU601;
+        default:
+            return frame->height >= 720 ? SWS_CS_ITU709 : SWS_CS_ITU601;
+    }
+}
+
+static AVFrame *scale_frame(AVFrame *frame, int output_fmt, int width, int height) {
     struct SwsContext *sws_ctx = NULL;
+    int xpos, ypos;
     AVFrame *output_frame = av_frame_alloc();
     if (!output_frame) {
         return output_frame;
     }
-    output_frame->height = frame->height;
-    output_frame->width = frame->width;
+    output_frame->height = height > 0 ? height : frame->height;
+    output_frame->width = width > 0 ? width : frame->width;
     output_frame->format = output_fmt;
     if (av_frame_get_buffer(output_frame, 1) < 0) {
         goto free;
     }
-    if (output_fmt == frame->format) {
+    if (output_fmt == frame->format &&
+        output_frame->width == frame->width && output_frame->height == frame->height) {
         if (av_frame_copy(output_frame, frame) < 0) {
             goto free;
         }
         goto done;
     }
-    sws_ctx = sws_getContext(frame->width, frame->height, frame->format,
-      output_frame->width, output_frame->height, output_fmt,
-      SWS_LANCZOS | SWS_ACCURATE_RND, NULL, NULL, NULL);
-    if (!sws_ctx) {
+    if (!(sws_ctx = sws_alloc_context())) {
+        goto free;
+    }
+    av_opt_set_int(sws_ctx, "srcw", frame->width, 0);
+    av_opt_set_int(sws_ctx, "srch", frame->height, 0);
+    av_opt_set_int(sws_ctx, "src_format", frame->format, 0);
+    av_opt_set_int(sws_ctx, "dstw", output_frame->width, 0);
+    av_opt_set_int(sws_ctx, "dsth", output_frame->height, 0);
+    av_opt_set_int(sws_ctx, "dst_format", output_fmt, 0);
+    av_opt_set_int(sws_ctx, "sws_flags", SWS_LANCZOS | SWS_ACCURATE_RND | SWS_FULL_CHR_H_INT | SWS_FULL_CHR_H_INP, 0);
+    if (frame->chroma_location != AVCHROMA_LOC_UNSPECIFIED &&
+        av_chroma_location_enum_to_pos(&xpos, &ypos, frame->chroma_location) == 0) {
+        av_opt_set_int(sws_ctx, "src_h_chr_pos", xpos, 0);
+        av_opt_set_int(sws_ctx, "src_v_chr_pos", ypos, 0);
+    }
+    if (sws_init_context(sws_ctx, NULL, NULL) < 0) {
         goto free;
     }
+    if (!is_rgb_format(frame->format)) {
+        sws_setColorspaceDetails(sws_ctx, sws_getCoefficients(sws_colorspace(frame)), is_full_range(frame),
+                                 sws_getCoefficients(SWS_CS_DEFAULT), 1, 0, 1 << 16, 1 << 16);
+    }
     if (sws_scale(sws_ctx, (const uint8_t *const *) frame->data, frame->linesize, 0, frame->height, output_frame->data,
                   output_frame->linesize) != output_frame->height) {
         goto free;
@@ -216,6 +292,249 @@ AVFrame *convert_frame_to_rgb(AVFrame *frame, int alpha) {
     return output_frame;
 }
 
+static double pq_eotf(double e) {
+    // PQ signal to linear light, 1.0 as 10000 nits
+    double p = pow(e, 1 / PQ_M2);
+    return pow(fmax(p - PQ_C1, 0) / (PQ_C2 - PQ_C3 * p), 1 / PQ_M1);
+}
+
+static double pq_oetf(double y) {
+    double p = pow(y, PQ_M1);
+    return pow((PQ_C1 + PQ_C2 * p) / (1 + PQ_C3 * p), PQ_M2);
+}
+
+static double hlg_inverse_oetf(double e) {
+    // HLG signal to scene linear light
+    if (e <= 0.5) {
+        return e * e / 3;
+    }
+    return (exp((e - HLG_C) / HLG_A) + HLG_B) / 12;
+}
+
+static double hable(double x) {
+    const double a = 0.15, b = 0.50, c = 0.10, d = 0.20, e = 0.02, f = 0.30;
+    return (x * (x * a + b * c) + d * e) / (x * (x * a + b) + d * f) - e / f;
+}
+
+static double mobius(double x, double peak) {
+    const double j = 0.3;
+    if (x <= j) {
+        return x;
+    }
+    double a = -j * j * (peak - 1) / (j * j - 2 * j + peak);
+    double b = (j * j - 2 * j * peak + peak) / fmax(peak - 1, 1e-6);
+    return (b * b + 2 * b * j + j * j) / (b - a) * (x + a) / (x + b);
+}
+
+static double bt2390(double x, double peak) {
+    // BT.2390 EETF in PQ domain, from source peak to SDR reference white
+    double src_peak = pq_oetf(peak * SDR_WHITE_NITS / 10000);
+    double max_lum = pq_oetf(SDR_WHITE_NITS / 10000.0) / src_peak;
+    double ks = 1.5 * max_lum - 0.5, e = pq_oetf(x * SDR_WHITE_NITS / 10000) / src_peak;
+    if (e > ks) {
+        double t = (e - ks) / (1 - ks), t2 = t * t, t3 = t2 * t;
+        e = (2 * t3 - 3 * t2 + 1) * ks + (t3 - 2 * t2 + t) * (1 - ks) + (-2 * t3 + 3 * t2) * max_lum;
+    }
+    return pq_eotf(e * src_peak) * 10000 / SDR_WHITE_NITS;
+}
+
+static double tone_map(int tonemap, double x, double peak) {
+    if (peak <= 1) {
+        return fmin(x, 1);
+    }
+    switch (tonemap) {
+        case TONEMAP_MOBIUS:
+            return mobius(x, peak);
+        case TONEMAP_BT2390:
+            return bt2390(x, peak);
+        default:
+            return hable(x) / hable(peak);
+    }
+}
+
+static int is_hdr_transfer(int color_trc) {
+    return color_trc == AVCOL_TRC_SMPTE2084 || color_trc == AVCOL_TRC_ARIB_STD_B67;
+}
+
+static int output_format(const ConvertOptions *opts) {
+    if (opts->depth == 16) {
+        return opts->alpha ? AV_PIX_FMT_RGBA64 : AV_PIX_FMT_RGB48;
+    }
+    return opts->alpha ? AV_PIX_FMT_RGBA : AV_PIX_FMT_RGB24;
+}
+
+static AVFrame *convert_hdr_frame_to_rgb(AVFrame *frame, const ConvertOptions *opts, int color_trc) {
+    static const double bt2020_to_bt709[3][3] = {
+        {1.660491, -0.587641, -0.072850},
+        {-0.124550, 1.132900, -0.008349},
+        {-0.018151, -0.100579, 1.118730},
+    };
+    int i, x, y, c, bands = opts->alpha ? 4 : 3;
+    int hlg = color_trc == AVCOL_TRC_ARIB_STD_B67;
+    int primaries = frame->color_primaries != AVCOL_PRI_UNSPECIFIED ? frame->color_primaries : opts->color_primaries;
+    double peak = hlg ? HLG_PEAK_NITS : opts->peak > 0 ? opts->peak : PQ_PEAK_NITS;
+    int max = opts->depth == 16 ? 65535 : 255;
+    double rgb[3], out[3], sig, ys;
+    float eotf[TONEMAP_LUT_SIZE];
+    uint16_t oetf[TONEMAP_LUT_SIZE];
+    peak /= SDR_WHITE_NITS;
+    for (i = 0; i < TONEMAP_LUT_SIZE; i++) {
+        double e = (double) i / (TONEMAP_LUT_SIZE - 1);
+        // linear light relative to SDR reference white, scene linear for HLG before OOTF
+        eotf[i] = (float) (hlg ? hlg_inverse_oetf(e) : pq_eotf(e) * 10000 / SDR_WHITE_NITS);
+        // BT.709 transfer
+        e = e < 0.018 ? 4.5 * e : 1.099 * pow(e, 0.45) - 0.099;
+        oetf[i] = (uint16_t) lrint(e * max);
+    }
+    AVFrame *linear = scale_frame(frame, opts->alpha ? AV_PIX_FMT_RGBA64 : AV_PIX_FMT_RGB48,
+                                  opts->width, opts->height);
+    if (!linear) {
+        return NULL;
+    }
+    AVFrame *output_frame = av_frame_alloc();
+    if (!output_frame) {
+        av_frame_free(&linear);
+        return NULL;
+    }
+    output_frame->width = linear->width;
+    output_frame->height = linear->height;
+    output_frame->format = output_format(opts);
+    if (av_frame_get_buffer(output_frame, 1) < 0) {
+        av_frame_free(&linear);
+        av_frame_free(&output_frame);
+        return NULL;
+    }
+    for (y = 0; y < linear->height; y++) {
+        const uint16_t *src = (const uint16_t *) (linear->data[0] + y * linear->linesize[0]);
+        uint8_t *dst = output_frame->data[0] + y * output_frame->linesize[0];
+        uint16_t *dst16 = (uint16_t *) dst;
+        for (x = 0; x < linear->width; x++, src += bands) {
+            for (c = 0; c < 3; c++) {
+                rgb[c] = eotf[src[c] * (TONEMAP_LUT_SIZE - 1) / 65535];
+            }
+            if (hlg) {
+                // HLG OOTF of system gamma 1.2 at nominal peak
+                ys = 0.2627 * rgb[0] + 0.6780 * rgb[1] + 0.0593 * rgb[2];
+                ys = ys > 0 ? pow(ys, 0.2) * peak : 0;
+                for (c = 0; c < 3; c++) {
+                    rgb[c] *= ys;
+                }
+            }
+            if (primaries == AVCOL_PRI_BT2020) {
+                for (c = 0; c < 3; c++) {
+                    out[c] = fmax(bt2020_to_bt709[c][0] * rgb[0] + bt2020_to_bt709[c][1] * rgb[1] +
+                                  bt2020_to_bt709[c][2] * rgb[2], 0);
+                }
+            } else {
+                memcpy(out, rgb, sizeof out);
+            }
+            // tone map by the max component to preserve hue
+            sig = fmax(out[0], fmax(out[1], out[2]));
+            sig = sig > 0 ? tone_map(opts->tonemap, sig, peak) / sig : 0;
+            for (c = 0; c < 3; c++) {
+                out[c] = oetf[(int) lrint(av_clipd(out[c] * sig, 0, 1) * (TONEMAP_LUT_SIZE - 1))];
+            }
+            if (max == 65535) {
+                for (c = 0; c < 3; c++) {
+                    *dst16++ = (uint16_t) out[c];
+                }
+                if (bands == 4) {
+                    *dst16++ = src[3];
+                }
+            } else {
+                for (c = 0; c < 3; c++) {
+                    *dst++ = (uint8_t) out[c];
+                }
+                if (bands == 4) {
+                    *dst++ = src[3] >> 8;
+                }
+            }
+        }
+    }
+    av_frame_free(&linear);
+    return output_frame;
+}
+
+static void blend_lines(uint8_t *data, int linesize, int bytes, int height, int wide, uint8_t *prev) {
+    int x, y;
+    for (y = 0; y < height; y++) {
+        uint8_t *cur = data + y * linesize;
+        const uint8_t *next = y + 1 < height ? cur + linesize : cur;
+        const uint8_t *last = y > 0 ? prev : cur;
+        // keep the original line as previous line of the next one
+        memcpy(prev + bytes, cur, bytes);
+        if (wide) {
+            uint16_t *c = (uint16_t *) cur;
+            const uint16_t *p = (const uint16_t *) last, *n = (const uint16_t *) next;
+            for (x = 0; x < bytes / 2; x++) {
+                c[x] = (p[x] + 2 * c[x] + n[x] + 2) >> 2;
+            }
+        } else {
+            for (x = 0; x < bytes; x++) {
+                cur[x] = (last[x] + 2 * cur[x] + next[x] + 2) >> 2;
+            }
+        }
+        memcpy(prev, prev + bytes, bytes);
+    }
+}
+
+static AVFrame *deinterlace_frame(AVFrame *frame) {
+    // linear blend of the two fields, each line blended with its neighbours of the other field
+    const AVPixFmtDescriptor *desc = av_pix_fmt_desc_get(frame->format);
+    int i, linesizes[4], wide;
+    uint8_t *prev = NULL;
+    if (!desc || desc->flags & (AV_PIX_FMT_FLAG_PAL | AV_PIX_FMT_FLAG_BITSTREAM |
+                                AV_PIX_FMT_FLAG_HWACCEL | AV_PIX_FMT_FLAG_FLOAT) ||
+        av_image_fill_linesizes(linesizes, frame->format, frame->width) < 0) {
+        return NULL;
+    }
+    wide = desc->comp[0].depth > 8;
+    AVFrame *output_frame = av_frame_alloc();
+    if (!output_frame) {
+        return NULL;
+    }
+    output_frame->format = frame->format;
+    output_frame->width = frame->width;
+    output_frame->height = frame->height;
+    if (av_frame_get_buffer(output_frame, 1) < 0 || av_frame_copy(output_frame, frame) < 0 ||
+        av_frame_copy_props(output_frame, frame) < 0) {
+        goto free;
+    }
+    for (i = 0; i < av_pix_fmt_count_planes(frame->format); i++) {
+        int height = i == 1 || i == 2 ? AV_CEIL_RSHIFT(frame->height, desc->log2_chroma_h) : frame->height;
+        if (!(prev = av_realloc(prev, 2 * linesizes[i]))) {
+            goto free;
+        }
+        blend_lines(output_frame->data[i], output_frame->linesize[i], linesizes[i], height, wide, prev);
+    }
+    output_frame->flags &= ~(AV_FRAME_FLAG_INTERLACED | AV_FRAME_FLAG_TOP_FIELD_FIRST);
+    av_free(prev);
+    return output_frame;
+    free:
+    av_free(prev);
+    av_frame_free(&output_frame);
+    return NULL;
+}
+
+AVFrame *convert_frame_to_rgb(AVFrame *frame, const ConvertOptions *opts) {
+    AVFrame *output_frame, *deinterlaced = NULL;
+    int color_trc = frame->color_trc != AVCOL_TRC_UNSPECIFIED ? frame->color_trc : opts->color_trc;
+    if (opts->deinterlace == DEINTERLACE_ON ||
+        (opts->deinterlace == DEINTERLACE_AUTO && frame->flags & AV_FRAME_FLAG_INTERLACED)) {
+        // fallback to the frame as is if the pixel format cannot be deinterlaced
+        if ((deinterlaced = deinterlace_frame(frame))) {
+            frame = deinterlaced;
+        }
+    }
+    if (opts->tonemap != TONEMAP_NONE && is_hdr_transfer(color_trc)) {
+        output_frame = convert_hdr_frame_to_rgb(frame, opts, color_trc);
+    } else {
+        output_frame = scale_frame(frame, output_format(opts), opts->width, opts->height);
+    }
+    av_frame_free(&deinterlaced);
+    return output_frame;
+}
+
 AVPacket *create_packet() {
     AVPacket *pkt = av_packet_alloc();
     if (!pkt) {
@@ -240,9 +559,15 @@ int obtain_next_frame(AVFormatContext *fmt_ctx, AVCodecContext *dec_ctx, int str
 
     while (1) {
         if ((err = av_read_frame(fmt_ctx, pkt)) < 0) {
+            if (err == AVERROR_EOF && avcodec_send_packet(dec_ctx, NULL) >= 0) {
+                // drain frames held by the decoder, such as for reordering
+                err = avcodec_receive_frame(dec_ctx, *frame);
+            }
             break;
         }
-        if (pkt->stream_index != stream_index) {
+        if (pkt->stream_index != stream_index ||
+            // keyframes only regardless of skip_frame support of the decoder
+            (dec_ctx->skip_frame >= AVDISCARD_NONKEY && !(pkt->flags & AV_PKT_FLAG_KEY))) {
             av_packet_unref(pkt);
             continue;
         }
@@ -283,6 +608,8 @@ ThumbContext *create_thumb_context(AVStream *stream, AVFrame *frame) {
     }
     int frames_in_128mb = (1 << 30) / (av_get_bits_per_pixel(thumb_ctx->desc) * frame->height * frame->width);
     thumb_ctx->max_frames = FFMIN(nb_frames, frames_in_128mb);
+    thumb_ctx->crop_left = frame->width;
+    thumb_ctx->crop_top = frame->height;
     int i;
     for (i = 0; i < thumb_ctx->desc->nb_components; i++) {
         thumb_ctx->hist_size += 1 << thumb_ctx->desc->comp[i].depth;
@@ -340,8 +667,170 @@ static double root_mean_square_error(const int *hist, const double *median, size
     return sum_sq_err;
 }
 
+static inline int read_component(const AVPixFmtDescriptor *desc, const AVComponentDescriptor *comp,
+                                 const AVFrame *frame, int x, int y) {
+    const uint8_t *p = frame->data[comp->plane] + y * frame->linesize[comp->plane] + x * comp->step + comp->offset;
+    int mask = (1 << comp->depth) - 1;
+    if (comp->shift + comp->depth <= 8) {
+        p += (desc->flags & AV_PIX_FMT_FLAG_BE) != 0;
+        return (*p >> comp->shift) & mask;
+    }
+    return ((desc->flags & AV_PIX_FMT_FLAG_BE ? AV_RB16(p) : AV_RL16(p)) >> comp->shift) & mask;
+}
+
+static inline int read_luma(const AVPixFmtDescriptor *desc, const AVFrame *frame, int x, int y) {
+    // luma in 8-bit scale, or the brightest of RGB components
+    int c, val, max = 0, n = desc->flags & AV_PIX_FMT_FLAG_RGB ? FFMIN(desc->nb_components, 3) : 1;
+    for (c = 0; c < n; c++) {
+        val = read_component(desc, &desc->comp[c], frame, x, y) * 255 / ((1 << desc->comp[c].depth) - 1);
+        max = FFMAX(max, val);
+    }
+    return max;
+}
+
+static int is_black_line(const AVPixFmtDescriptor *desc, const AVFrame *frame,
+                         int x, int y, int dx, int dy, int len) {
+    int i, step = FFMAX(1, len / CROP_SAMPLE_SIZE), count = 0, total = 0;
+    for (i = 0; i < len; i += step) {
+        total += read_luma(desc, frame, x + i * dx, y + i * dy);
+        count++;
+    }
+    return total <= CROP_LIMIT * count;
+}
+
+static void detect_crop(ThumbContext *thumb_ctx, const AVFrame *frame) {
+    const AVPixFmtDescriptor *desc = thumb_ctx->desc;
+    int top, bottom, left, right, w = frame->width, h = frame->height;
+    if (desc->flags & (AV_PIX_FMT_FLAG_BITSTREAM | AV_PIX_FMT_FLAG_PAL)) {
+        return;
+    }
+    // active picture of the frame, skipping black borders as of cropdetect
+    for (top = 0; top < h && is_black_line(desc, frame, 0, top, 1, 0, w); top++);
+    for (bottom = h; bottom > top && is_black_line(desc, frame, 0, bottom - 1, 1, 0, w); bottom--);
+    for (left = 0; left < w && is_black_line(desc, frame, left, top, 0, 1, bottom - top); left++);
+    for (right = w; right > left && is_black_line(desc, frame, right - 1, top, 0, 1, bottom - top); right--);
+    if (top >= bottom || left >= right) {
+        // skip black frame
+        return;
+    }
+    // union of active pictures across frames
+    thumb_ctx->crop_left = FFMIN(thumb_ctx->crop_left, left);
+    thumb_ctx->crop_top = FFMIN(thumb_ctx->crop_top, top);
+    thumb_ctx->crop_right = FFMAX(thumb_ctx->crop_right, right);
+    thumb_ctx->crop_bottom = FFMAX(thumb_ctx->crop_bottom, bottom);
+}
+
 void populate_frame(ThumbContext *thumb_ctx, int n, AVFrame *frame) {
     thumb_ctx->frames[n].frame = frame;
+    if (thumb_ctx->crop) {
+        detect_crop(thumb_ctx, frame);
+    }
+}
+
+static void histogram_stats(const int *hist, int size, double *mean, double *variance, double *entropy) {
+    int i;
+    double total = 0, sum = 0, sum_sq = 0, p;
+    for (i = 0; i < size; i++) {
+        total += hist[i];
+        sum += (double) i * hist[i];
+        sum_sq += (double) i * i * hist[i];
+    }
+    *mean = *variance = *entropy = 0;
+    if (total <= 0) {
+        return;
+    }
+    *mean = sum / total;
+    *variance = sum_sq / total - *mean * *mean;
+    for (i = 0; i < size; i++) {
+        if (hist[i] > 0) {
+            p = hist[i] / total;
+            *entropy -= p * log2(p);
+        }
+    }
+}
+
+static double frame_entropy(const AVPixFmtDescriptor *desc, const int *hist) {
+    double mean, variance, entropy;
+    // luma entropy, or average entropy of components for RGB
+    int i, nb_components = desc->flags & AV_PIX_FMT_FLAG_RGB ? FFMIN(desc->nb_components, 3) : 1;
+    double sum = 0;
+    for (i = 0; i < nb_components; i++) {
+        histogram_stats(hist, 1 << desc->comp[i].depth, &mean, &variance, &entropy);
+        sum += entropy;
+        hist += 1 << desc->comp[i].depth;
+    }
+    return sum / nb_components;
+}
+
+static double frame_colorfulness(const AVPixFmtDescriptor *desc, const int *hist) {
+    double mean[3], variance[3], entropy, scale, rg_mean, yb_mean, rg_var, yb_var;
+    int i;
+    if (desc->nb_components < 3) {
+        return 0;
+    }
+    for (i = 0; i < 3; i++) {
+        histogram_stats(hist, 1 << desc->comp[i].depth, &mean[i], &variance[i], &entropy);
+        // normalize to 8 bit scale
+        scale = (double) (1 << desc->comp[i].depth) / 256;
+        mean[i] /= scale;
+        variance[i] /= scale * scale;
+        hist += 1 << desc->comp[i].depth;
+    }
+    if (desc->flags & AV_PIX_FMT_FLAG_RGB) {
+        // Hasler and Suesstrunk opponent color space,
+        // approximated from per-component histograms
+        rg_mean = mean[0] - mean[1];
+        yb_mean = 0.5 * (mean[0] + mean[1]) - mean[2];
+        rg_var = variance[0] + variance[1];
+        yb_var = 0.25 * (variance[0] + variance[1]) + variance[2];
+    } else {
+        // chroma deviation from neutral for YUV
+        rg_mean = mean[2] - 128;
+        yb_mean = mean[1] - 128;
+        rg_var = variance[2];
+        yb_var = variance[1];
+    }
+    return sqrt(rg_var + yb_var) + 0.3 * sqrt(rg_mean * rg_mean + yb_mean * yb_mean);
+}
+
+static double frame_sharpness(const AVPixFmtDescriptor *desc, const AVFrame *frame) {
+    const AVComponentDescriptor *comp = &desc->comp[0];
+    if (desc->flags & (AV_PIX_FMT_FLAG_BITSTREAM | AV_PIX_FMT_FLAG_PAL)) {
+        return 0;
+    }
+    // variance of Laplacian over sampled grid of luma
+    int x, y, step = FFMAX(1, FFMAX(frame->width, frame->height) / SHARPNESS_SAMPLE_SIZE);
+    double scale = (double) (1 << comp->depth) / 256, lap, sum = 0, sum_sq = 0, count = 0;
+    for (y = step; y + step < frame->height; y += step) {
+        for (x = step; x + step < frame->width; x += step) {
+            lap = 4 * read_component(desc, comp, frame, x, y) -
+                  read_component(desc, comp, frame, x - step, y) -
+                  read_component(desc, comp, frame, x + step, y) -
+                  read_component(desc, comp, frame, x, y - step) -
+                  read_component(desc, comp, frame, x, y + step);
+            lap /= scale;
+            sum += lap;
+            sum_sq += lap * lap;
+            count++;
+        }
+    }
+    if (count == 0) {
+        return 0;
+    }
+    return sum_sq / count - (sum / count) * (sum / count);
+}
+
+static double score_frame(ThumbContext *thumb_ctx, AVFrame *frame, const int *hist) {
+    switch (thumb_ctx->strategy) {
+        case STRATEGY_SHARPNESS:
+            return frame_sharpness(thumb_ctx->desc, frame);
+        case STRATEGY_ENTROPY:
+            return frame_entropy(thumb_ctx->desc, hist);
+        case STRATEGY_COLORFULNESS:
+            return frame_colorfulness(thumb_ctx->desc, hist);
+        default:
+            return 0;
+    }
 }
 
 void populate_histogram(ThumbContext *thumb_ctx, int n, AVFrame *frame) {
@@ -396,12 +885,46 @@ void populate_histogram(ThumbContext *thumb_ctx, int n, AVFrame *frame) {
         }
         hist += 1 << depth;
     }
+    thumb_ctx->frames[n].rejected = 0;
+    if (thumb_ctx->crop) {
+        detect_crop(thumb_ctx, frame);
+    }
+    if (thumb_ctx->strategy != STRATEGY_RMSE && thumb_ctx->strategy != STRATEGY_CUSTOM) {
+        thumb_ctx->frames[n].score = score_frame(thumb_ctx, frame, thumb_ctx->frames[n].hist);
+    }
 }
 
-int find_best_frame_index(ThumbContext *thumb_ctx) {
-    int i, j, n = 0, m = thumb_ctx->n, *hist = NULL;
+void set_frame_score(ThumbContext *thumb_ctx, int n, double score, int rejected) {
+    thumb_ctx->frames[n].score = score;
+    thumb_ctx->frames[n].rejected = rejected;
+}
+
+static double histogram_difference(const int *a, const int *b, size_t hist_size) {
+    int i;
+    double diff = 0, sum = 0;
+    for (i = 0; i < hist_size; i++) {
+        diff += abs(a[i] - b[i]);
+        sum += a[i] + b[i];
+    }
+    return sum > 0 ? diff / sum : 0;
+}
+
+static int find_best_frame_in_range(ThumbContext *thumb_ctx, int start, int end) {
+    int i, j, n = start, m = end - start, *hist = NULL;
+    if (thumb_ctx->strategy != STRATEGY_RMSE) {
+        double max_score = -DBL_MAX;
+        n = -1;
+        for (i = start; i < end; i++) {
+            if (!thumb_ctx->frames[i].rejected && (n < 0 || thumb_ctx->frames[i].score > max_score)) {
+                max_score = thumb_ctx->frames[i].score;
+                n = i;
+            }
+        }
+        return n;
+    }
     double *median = thumb_ctx->median;
-    for (j = 0; j < m; j++) {
+    memset(median, 0, thumb_ctx->hist_size * sizeof(double));
+    for (j = start; j < end; j++) {
         hist = thumb_ctx->frames[j].hist;
         for (i = 0; i < thumb_ctx->hist_size; i++) {
             median[i] += (double) hist[i] / m;
@@ -409,7 +932,7 @@ int find_best_frame_index(ThumbContext *thumb_ctx) {
     }
     struct thumb_frame *t_frame = NULL;
     double min_sum_sq_err = DBL_MAX, sum_sq_err = 0;
-    for (i = 0; i < thumb_ctx->n; i++) {
+    for (i = start; i < end; i++) {
         t_frame = thumb_ctx->frames + i;
         sum_sq_err = root_mean_square_error(t_frame->hist, thumb_ctx->median, thumb_ctx->hist_size);
         if (sum_sq_err < min_sum_sq_err) {
@@ -420,6 +943,41 @@ int find_best_frame_index(ThumbContext *thumb_ctx) {
     return n;
 }
 
+static int find_scene_frame_index(ThumbContext *thumb_ctx) {
+    int i, n = thumb_ctx->n, start = 0, best_start = 0, best_end = 0;
+    // split into shots at scene cuts, then keep the longest shot
+    for (i = 1; i <= n; i++) {
+        if (i == n || histogram_difference(thumb_ctx->frames[i - 1].hist, thumb_ctx->frames[i].hist,
+                                           thumb_ctx->hist_size) > SCENE_CUT_THRESHOLD) {
+            if (i - start > best_end - best_start) {
+                best_start = start;
+                best_end = i;
+            }
+            start = i;
+        }
+    }
+    // skip transition frames next to the cuts
+    start = best_start > 0 ? best_start + SCENE_CUT_MARGIN : best_start;
+    i = best_end < n ? best_end - SCENE_CUT_MARGIN : best_end;
+    if (start < i) {
+        best_start = start;
+        best_end = i;
+    }
+    i = find_best_frame_in_range(thumb_ctx, best_start, best_end);
+    if (i < 0) {
+        // every frame of the shot rejected, fallback to the whole range
+        return find_best_frame_in_range(thumb_ctx, 0, n);
+    }
+    return i;
+}
+
+int find_best_frame_index(ThumbContext *thumb_ctx) {
+    if (thumb_ctx->scene) {
+        return find_scene_frame_index(thumb_ctx);
+    }
+    return find_best_frame_in_range(thumb_ctx, 0, thumb_ctx->n);
+}
+
 AVFrame *select_frame(ThumbContext *thumb_ctx, int n) {
     return thumb_ctx->frames[n].frame;
 }
@@ -6,6 +6,7 @@ package ffmpeg
 // #include "ffmpeg.h"
 import "C"
 import (
+	"fmt"
 	"io"
 	"math"
 	"runtime/cgo"
@@ -18,19 +19,90 @@ const (
 	seekPacketFlag = 2
 	hasVideo       = 1
 	hasAudio       = 2
+	sampleMargin   = 0.05
 )
 
 // Metadata AV metadata
 type Metadata struct {
-	Orientation int     `json:"orientation"`
-	Duration    int     `json:"duration,omitempty"`
-	Width       int     `json:"width,omitempty"`
-	Height      int     `json:"height,omitempty"`
-	Title       string  `json:"title,omitempty"`
-	Artist      string  `json:"artist,omitempty"`
-	FPS         float64 `json:"fps,omitempty"`
-	HasVideo    bool    `json:"has_video"`
-	HasAudio    bool    `json:"has_audio"`
+	Orientation int       `json:"orientation"`
+	FormatName  string    `json:"format_name,omitempty"`
+	Duration    int       `json:"duration,omitempty"`
+	BitRate     int64     `json:"bit_rate,omitempty"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	SAR         string    `json:"sample_aspect_ratio,omitempty"`
+	DAR         string    `json:"display_aspect_ratio,omitempty"`
+	Title       string    `json:"title,omitempty"`
+	Artist      string    `json:"artist,omitempty"`
+	Album       string    `json:"album,omitempty"`
+	AlbumArtist string    `json:"album_artist,omitempty"`
+	Genre       string    `json:"genre,omitempty"`
+	Track       string    `json:"track,omitempty"`
+	Disc        string    `json:"disc,omitempty"`
+	Date        string    `json:"date,omitempty"`
+	Composer    string    `json:"composer,omitempty"`
+	Lyrics      string    `json:"lyrics,omitempty"`
+	FPS         float64   `json:"fps,omitempty"`
+	HasVideo    bool      `json:"has_video"`
+	HasAudio    bool      `json:"has_audio"`
+	Crop        *Crop     `json:"crop,omitempty"`
+	FastDecode  bool      `json:"fast_decode,omitempty"`
+	Interlaced  bool      `json:"interlaced,omitempty"`
+	HasCover    bool      `json:"has_cover,omitempty"`
+	Streams     []Stream  `json:"streams,omitempty"`
+	Chapters    []Chapter `json:"chapters,omitempty"`
+	Audio       *Audio    `json:"audio,omitempty"`
+
+	DisplayWidth  int `json:"display_width,omitempty"`
+	DisplayHeight int `json:"display_height,omitempty"`
+	Rotation      int `json:"rotation,omitempty"`
+
+	IsHDR            bool              `json:"is_hdr,omitempty"`
+	ColorTransfer    string            `json:"color_transfer,omitempty"`
+	ColorPrimaries   string            `json:"color_primaries,omitempty"`
+	MasteringDisplay *MasteringDisplay `json:"mastering_display,omitempty"`
+	ContentLight     *ContentLight     `json:"content_light,omitempty"`
+
+	CreationTime string    `json:"creation_time,omitempty"`
+	Make         string    `json:"make,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	Location     *Location `json:"location,omitempty"`
+
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// MasteringDisplay HDR mastering display color volume,
+// chromaticity coordinates of primaries and white point as [x, y], luminance in nits
+type MasteringDisplay struct {
+	Red          [2]float64 `json:"red"`
+	Green        [2]float64 `json:"green"`
+	Blue         [2]float64 `json:"blue"`
+	WhitePoint   [2]float64 `json:"white_point"`
+	MinLuminance float64    `json:"min_luminance"`
+	MaxLuminance float64    `json:"max_luminance"`
+}
+
+// ContentLight HDR content light level in nits
+type ContentLight struct {
+	MaxCLL  int `json:"max_cll"`
+	MaxFALL int `json:"max_fall"`
+}
+
+// Audio codec, sample rate and channels of the best audio stream
+type Audio struct {
+	Codec         string `json:"codec"`
+	SampleRate    int    `json:"sample_rate,omitempty"`
+	Channels      int    `json:"channels,omitempty"`
+	ChannelLayout string `json:"channel_layout,omitempty"`
+	BitRate       int64  `json:"bit_rate,omitempty"`
+}
+
+// Crop active picture rectangle without black borders of the exported frames, before orientation
+type Crop struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Right  int `json:"right"`
+	Bottom int `json:"bottom"`
 }
 
 // AVContext manages lifecycle of AV contexts and reader stream
@@ -52,9 +124,33 @@ type AVContext struct {
 	availableIndex     C.int
 	availableDuration  time.Duration
 	width, height      int
+	codedWidth         int
+	codedHeight        int
+	sampleAspectRatio  C.AVRational
 	title, artist      string
 	hasVideo, hasAudio bool
 	closed             bool
+	sceneDetection     bool
+	strategy           Strategy
+	scorer             FrameScorer
+	cropDetection      bool
+	samples            int
+	seekedDuration     time.Duration
+	seekedEnd          time.Duration
+	fastDecode         bool
+	exportWidth        int
+	exportHeight       int
+	exportDepth        int
+	toneMapping        ToneMapping
+	deinterlace        Deinterlace
+	streamIndex        int
+	streamMatch        string
+	cover              string
+	isHDR              bool
+	colorTransfer      string
+	colorPrimaries     string
+	masteringDisplay   *MasteringDisplay
+	contentLight       *ContentLight
 }
 
 type opaqueHandle struct {
@@ -62,11 +158,15 @@ type opaqueHandle struct {
 }
 
 // LoadAVContext load and create AVContext from reader stream
-func LoadAVContext(reader io.Reader, size int64) (*AVContext, error) {
+func LoadAVContext(reader io.Reader, size int64, options ...Option) (*AVContext, error) {
 	av := &AVContext{
 		reader:        reader,
 		size:          size,
 		selectedIndex: -1,
+		streamIndex:   -1,
+	}
+	for _, option := range options {
+		option(av)
 	}
 	if seeker, ok := reader.(io.Seeker); ok {
 		av.seeker = seeker
@@ -93,6 +193,9 @@ func (av *AVContext) ProcessFrames(maxFrames int) (err error) {
 	if av.thumbContext == nil {
 		return createThumbContext(av, C.int(maxFrames))
 	}
+	if av.scorer != nil && av.selectedIndex < 0 {
+		return ErrFrameRejected
+	}
 	return
 }
 
@@ -139,9 +242,58 @@ func (av *AVContext) SeekDuration(ts time.Duration) error {
 	if av.formatContext == nil || av.codecContext == nil {
 		return ErrDecoderNotFound
 	}
+	av.seekedDuration = ts
 	return seekDuration(av, ts)
 }
 
+// SeekChapter seeks to keyframe before the start of chapter n, 1-based,
+// with frame processing limited to frames within the chapter
+func (av *AVContext) SeekChapter(n int) error {
+	chs := formatChapters(av)
+	if n < 1 || n > len(chs) {
+		return ErrChapterNotFound
+	}
+	start, end := chapterDurations(chs[n-1])
+	if err := av.SeekDuration(start); err != nil {
+		return err
+	}
+	if end > start {
+		av.seekedEnd = end
+	}
+	return nil
+}
+
+// SetExportSize sets dimensions of the exported frame, which is downscaled on conversion.
+// Before frame processing, it also reopens decoder with lowres decoding where the codec supports it
+func (av *AVContext) SetExportSize(width, height int) error {
+	av.exportWidth, av.exportHeight = width, height
+	if av.codecContext == nil || av.thumbContext != nil || av.cropDetection ||
+		av.codecContext.codec == nil || av.codecContext.codec.max_lowres == 0 {
+		return nil
+	}
+	C.avcodec_free_context(&av.codecContext)
+	return createDecoder(av)
+}
+
+// SetExportDepth sets bit depth per channel of the exported frames, either 8 or 16.
+// 16-bit buffers hold native-endian uint16 samples, twice the size of 8-bit ones
+func (av *AVContext) SetExportDepth(depth int) {
+	if depth != 16 {
+		depth = 8
+	}
+	av.exportDepth = depth
+}
+
+// AttachedPicture encoded image of the selected attached picture as is,
+// such as JPEG or PNG cover art of audio. Nil if the video stream is not an attached picture
+func (av *AVContext) AttachedPicture() []byte {
+	if av.closed || av.stream == nil || av.stream.disposition&C.AV_DISPOSITION_ATTACHED_PIC == 0 ||
+		av.stream.attached_pic.size <= 0 {
+		return nil
+	}
+	return C.GoBytes(unsafe.Pointer(av.stream.attached_pic.data), av.stream.attached_pic.size)
+}
+
 // Export frame to RGB or RGBA buffer
 func (av *AVContext) Export(bands int) (buf []byte, err error) {
 	if err = av.ProcessFrames(-1); err != nil {
@@ -156,6 +308,33 @@ func (av *AVContext) Export(bands int) (buf []byte, err error) {
 	return exportBuffer(av, bands)
 }
 
+// ExportFrames seeks to each of the specified durations within a single decoding session,
+// then exports the first frame at or after each duration to RGB or RGBA buffer,
+// downscaled to the export size if set
+func (av *AVContext) ExportFrames(durations []time.Duration, bands int) (bufs [][]byte, err error) {
+	bufs = make([][]byte, 0, len(durations))
+	err = av.ExportFramesFunc(durations, bands, func(_ int, buf []byte) error {
+		bufs = append(bufs, buf)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// ExportFramesFunc is similar to ExportFrames,
+// but passes each exported buffer to fn once available instead of holding all of them
+func (av *AVContext) ExportFramesFunc(durations []time.Duration, bands int, fn func(i int, buf []byte) error) error {
+	if av.formatContext == nil || av.codecContext == nil {
+		return ErrDecoderNotFound
+	}
+	if bands < 3 || bands > 4 {
+		bands = 4
+	}
+	return exportFrames(av, durations, bands, fn)
+}
+
 // Close AVContext objects
 func (av *AVContext) Close() {
 	closeAVContext(av)
@@ -167,17 +346,131 @@ func (av *AVContext) Metadata() *Metadata {
 	if av.stream != nil {
 		fps = float64(av.stream.r_frame_rate.num) / float64(av.stream.r_frame_rate.den)
 	}
-	return &Metadata{
+	sar, dar := aspectRatios(av)
+	width, height := av.width, av.height
+	if av.codedWidth > 0 {
+		width, height = av.codedWidth, av.codedHeight
+	}
+	rotation := orientationRotation(av.orientation)
+	displayWidth, displayHeight := av.width, av.height
+	if rotation == 90 || rotation == 270 {
+		displayWidth, displayHeight = av.height, av.width
+	}
+	var formatName string
+	var bitRate int64
+	if !av.closed && av.formatContext != nil {
+		formatName = C.GoString(av.formatContext.iformat.name)
+		bitRate = int64(av.formatContext.bit_rate)
+	}
+	meta := &Metadata{
 		Orientation: av.orientation,
+		FormatName:  formatName,
 		Duration:    int(av.duration / time.Millisecond),
-		Width:       av.width,
-		Height:      av.height,
+		BitRate:     bitRate,
+		Width:       width,
+		Height:      height,
+		SAR:         sar,
+		DAR:         dar,
 		Title:       av.title,
 		Artist:      av.artist,
 		FPS:         fps,
 		HasVideo:    av.hasVideo,
 		HasAudio:    av.hasAudio,
+		Crop:        crop(av),
+		FastDecode:  av.fastDecode && av.codecContext != nil,
+		Interlaced:  interlaced(av),
+		HasCover:    hasCover(av),
+		Streams:     streams(av),
+		Chapters:    chapters(av),
+		Audio:       audio(av),
+
+		DisplayWidth:  displayWidth,
+		DisplayHeight: displayHeight,
+		Rotation:      rotation,
+
+		IsHDR:            av.isHDR,
+		ColorTransfer:    av.colorTransfer,
+		ColorPrimaries:   av.colorPrimaries,
+		MasteringDisplay: av.masteringDisplay,
+		ContentLight:     av.contentLight,
+	}
+	mediaTags(av, meta)
+	return meta
+}
+
+// FrameSize dimensions of the exported frames,
+// which are of the sample aspect ratio applied, before orientation
+func (m *Metadata) FrameSize() (width, height int) {
+	width, height = m.DisplayWidth, m.DisplayHeight
+	if m.Rotation == 90 || m.Rotation == 270 {
+		width, height = height, width
+	}
+	return
+}
+
+// orientationRotation clockwise rotation in degrees of EXIF orientation, regardless of mirroring
+func orientationRotation(orientation int) int {
+	switch orientation {
+	case 3, 4:
+		return 180
+	case 5, 6:
+		return 90
+	case 7, 8:
+		return 270
+	default:
+		return 0
+	}
+}
+
+func crop(av *AVContext) *Crop {
+	tc := av.thumbContext
+	if tc == nil || tc.crop == 0 || tc.crop_right <= tc.crop_left || tc.crop_bottom <= tc.crop_top {
+		return nil
+	}
+	c := &Crop{
+		Left:   int(tc.crop_left),
+		Top:    int(tc.crop_top),
+		Right:  int(tc.crop_right),
+		Bottom: int(tc.crop_bottom),
+	}
+	if av.codedWidth > 0 {
+		// from coded to display dimensions of anamorphic video
+		scale := float64(av.width) / float64(av.codedWidth)
+		c.Left = int(math.Round(float64(c.Left) * scale))
+		c.Right = int(math.Round(float64(c.Right) * scale))
+	}
+	return c
+}
+
+// interlaced whether the video stream is of interlaced field order,
+// or the selected frame is flagged as interlaced once frames processed
+func interlaced(av *AVContext) bool {
+	if av.stream == nil || !av.hasVideo {
+		return false
+	}
+	switch av.stream.codecpar.field_order {
+	case C.AV_FIELD_TT, C.AV_FIELD_BB, C.AV_FIELD_TB, C.AV_FIELD_BT:
+		return true
+	}
+	if av.thumbContext == nil || av.selectedIndex < 0 {
+		return false
 	}
+	frame := C.select_frame(av.thumbContext, av.selectedIndex)
+	return frame != nil && frame.flags&C.AV_FRAME_FLAG_INTERLACED != 0
+}
+
+// aspectRatios sample and display aspect ratios of video, 1:1 sample aspect ratio unless anamorphic
+func aspectRatios(av *AVContext) (sar, dar string) {
+	if av.width <= 0 || av.height <= 0 {
+		return
+	}
+	var num, den C.int
+	width, height, r := av.width, av.height, C.AVRational{num: 1, den: 1}
+	if av.codedWidth > 0 {
+		width, height, r = av.codedWidth, av.codedHeight, av.sampleAspectRatio
+	}
+	C.av_reduce(&num, &den, C.int64_t(width)*C.int64_t(r.num), C.int64_t(height)*C.int64_t(r.den), 1<<30)
+	return fmt.Sprintf("%d:%d", r.num, r.den), fmt.Sprintf("%d:%d", num, den)
 }
 
 func closeAVContext(av *AVContext) {
@@ -262,7 +555,11 @@ func duration(av *AVContext) {
 
 func findStreams(av *AVContext) error {
 	var orientation C.int
-	err := C.find_streams(av.formatContext, &av.stream, &orientation)
+	wanted, e := wantedStream(av)
+	if e != nil {
+		return e
+	}
+	err := C.find_streams(av.formatContext, &av.stream, &orientation, C.int(wanted))
 	if err < 0 {
 		return avError(err)
 	}
@@ -272,15 +569,85 @@ func findStreams(av *AVContext) error {
 		av.width = int(av.stream.codecpar.width)
 		av.height = int(av.stream.codecpar.height)
 		av.orientation = int(orientation)
+		anamorphic(av)
+		hdrMetadata(av)
 	}
 	return nil
 }
 
+func rationalToFloat(r C.AVRational) float64 {
+	if r.den == 0 {
+		return 0
+	}
+	return float64(r.num) / float64(r.den)
+}
+
+// anamorphic stretches the coded width by the sample aspect ratio,
+// so that frames are exported at display dimensions
+func anamorphic(av *AVContext) {
+	sar := C.av_guess_sample_aspect_ratio(av.formatContext, av.stream, nil)
+	if sar.num <= 0 || sar.den <= 0 || sar.num == sar.den || av.width <= 0 || av.height <= 0 {
+		return
+	}
+	av.sampleAspectRatio = sar
+	av.codedWidth, av.codedHeight = av.width, av.height
+	av.width = max(int(math.Round(float64(av.width)*rationalToFloat(sar))), 1)
+}
+
+// hdrMetadata color and side data of HDR video stream
+func hdrMetadata(av *AVContext) {
+	par := av.stream.codecpar
+	if par.color_trc != C.AVCOL_TRC_SMPTE2084 && par.color_trc != C.AVCOL_TRC_ARIB_STD_B67 {
+		return
+	}
+	av.isHDR = true
+	av.colorTransfer = C.GoString(C.av_color_transfer_name(par.color_trc))
+	if par.color_primaries != C.AVCOL_PRI_UNSPECIFIED {
+		av.colorPrimaries = C.GoString(C.av_color_primaries_name(par.color_primaries))
+	}
+	if sd := C.av_packet_side_data_get(par.coded_side_data, par.nb_coded_side_data,
+		C.AV_PKT_DATA_MASTERING_DISPLAY_METADATA); sd != nil {
+		md := (*C.AVMasteringDisplayMetadata)(unsafe.Pointer(sd.data))
+		av.masteringDisplay = &MasteringDisplay{}
+		if md.has_primaries != 0 {
+			for i, xy := range []*[2]float64{
+				&av.masteringDisplay.Red, &av.masteringDisplay.Green, &av.masteringDisplay.Blue,
+			} {
+				xy[0] = rationalToFloat(md.display_primaries[i][0])
+				xy[1] = rationalToFloat(md.display_primaries[i][1])
+			}
+			av.masteringDisplay.WhitePoint[0] = rationalToFloat(md.white_point[0])
+			av.masteringDisplay.WhitePoint[1] = rationalToFloat(md.white_point[1])
+		}
+		if md.has_luminance != 0 {
+			av.masteringDisplay.MinLuminance = rationalToFloat(md.min_luminance)
+			av.masteringDisplay.MaxLuminance = rationalToFloat(md.max_luminance)
+		}
+	}
+	if sd := C.av_packet_side_data_get(par.coded_side_data, par.nb_coded_side_data,
+		C.AV_PKT_DATA_CONTENT_LIGHT_LEVEL); sd != nil {
+		cl := (*C.AVContentLightMetadata)(unsafe.Pointer(sd.data))
+		av.contentLight = &ContentLight{
+			MaxCLL:  int(cl.MaxCLL),
+			MaxFALL: int(cl.MaxFALL),
+		}
+	}
+}
+
 func createDecoder(av *AVContext) error {
-	err := C.create_codec_context(av.stream, &av.codecContext)
+	width := av.exportWidth
+	if av.codedWidth > 0 {
+		// lowres decoding is in coded dimensions
+		width = width * av.codedWidth / av.width
+	}
+	err := C.create_codec_context(av.stream, &av.codecContext, C.int(width), C.int(av.exportHeight))
 	if err < 0 {
 		return avError(err)
 	}
+	if av.fastDecode {
+		av.codecContext.skip_frame = C.AVDISCARD_NONKEY
+		av.codecContext.skip_loop_filter = C.AVDISCARD_ALL
+	}
 	return nil
 }
 
@@ -294,6 +661,11 @@ func seekDuration(av *AVContext, ts time.Duration) error {
 	return nil
 }
 
+func ptsToDuration(av *AVContext, pts C.int64_t) time.Duration {
+	tb := av.stream.time_base
+	return time.Duration(float64(pts) * float64(tb.num) / float64(tb.den) * float64(time.Second))
+}
+
 func incrementDuration(av *AVContext, frame *C.AVFrame, i C.int) {
 	av.availableIndex = i
 	if frame.pts != C.AV_NOPTS_VALUE {
@@ -315,6 +687,9 @@ func populateFrames(av *AVContext, frames <-chan *C.AVFrame) <-chan struct{} {
 		if !isSelected {
 			for frame := range frames {
 				C.populate_histogram(av.thumbContext, n, frame)
+				if av.scorer != nil {
+					scoreFrame(av, n, frame)
+				}
 				n++
 			}
 		} else {
@@ -329,6 +704,45 @@ func populateFrames(av *AVContext, frames <-chan *C.AVFrame) <-chan struct{} {
 	return done
 }
 
+// keyframeSamples sample points spread across the duration for sampling mode,
+// from the seeked duration if any, skipping the margins at start and end
+func keyframeSamples(av *AVContext) []time.Duration {
+	if av.samples <= 0 || av.duration <= 0 || av.selectedIndex > -1 || av.selectedDuration > 0 ||
+		av.stream.disposition&C.AV_DISPOSITION_ATTACHED_PIC != 0 {
+		return nil
+	}
+	margin := time.Duration(float64(av.duration) * sampleMargin)
+	start, end := max(av.seekedDuration, margin), av.duration-margin
+	if av.seekedEnd > 0 {
+		end = min(end, av.seekedEnd)
+	}
+	if start >= end {
+		return nil
+	}
+	samples := make([]time.Duration, av.samples)
+	for i := range samples {
+		samples[i] = start + time.Duration((float64(i)+0.5)/float64(av.samples)*float64(end-start))
+	}
+	return samples
+}
+
+// obtainFrame obtains next frame, or the keyframe at or before the i-th sample point if sampling.
+// Frames before the start of the seeked chapter are skipped
+func obtainFrame(av *AVContext, pkt *C.AVPacket, frame **C.AVFrame, samples []time.Duration, i C.int) C.int {
+	if samples != nil {
+		if err := seekDuration(av, samples[i]); err != nil {
+			return C.int(err.(avError))
+		}
+	}
+	err := C.obtain_next_frame(av.formatContext, av.codecContext, av.stream.index, pkt, frame)
+	// skip frames decoded from the keyframe before the chapter start
+	for samples == nil && av.seekedEnd > 0 && err >= 0 && (*frame).pts != C.AV_NOPTS_VALUE &&
+		ptsToDuration(av, (*frame).pts) < av.seekedDuration {
+		err = C.obtain_next_frame(av.formatContext, av.codecContext, av.stream.index, pkt, frame)
+	}
+	return err
+}
+
 func createThumbContext(av *AVContext, maxFrames C.int) error {
 	pkt := C.create_packet()
 	if pkt == nil {
@@ -337,12 +751,25 @@ func createThumbContext(av *AVContext, maxFrames C.int) error {
 	defer C.av_packet_free(&pkt)
 
 	var frame *C.AVFrame
-	err := C.obtain_next_frame(av.formatContext, av.codecContext, av.stream.index, pkt, &frame)
+	samples := keyframeSamples(av)
+	err := obtainFrame(av, pkt, &frame, samples, 0)
 	if err >= 0 {
 		incrementDuration(av, frame, 0)
 		av.thumbContext = C.create_thumb_context(av.stream, frame)
 		if av.thumbContext == nil {
 			err = C.int(ErrNoMem)
+		} else {
+			if av.sceneDetection && samples == nil {
+				// scene cuts are between consecutive frames, not the distant samples
+				av.thumbContext.scene = 1
+			}
+			if av.cropDetection {
+				av.thumbContext.crop = 1
+			}
+			av.thumbContext.strategy = C.int(av.strategy)
+			if av.scorer != nil {
+				av.thumbContext.strategy = C.STRATEGY_CUSTOM
+			}
 		}
 	}
 	if err < 0 {
@@ -355,6 +782,9 @@ func createThumbContext(av *AVContext, maxFrames C.int) error {
 	if maxFrames > 0 && n > maxFrames {
 		n = maxFrames
 	}
+	if samples != nil && n > C.int(len(samples)) {
+		n = C.int(len(samples))
+	}
 	if av.selectedIndex > -1 && n > av.selectedIndex+1 {
 		n = av.selectedIndex + 1
 	}
@@ -364,10 +794,12 @@ func createThumbContext(av *AVContext, maxFrames C.int) error {
 	frames := make(chan *C.AVFrame, n)
 	done := populateFrames(av, frames)
 	frames <- frame
-	return populateThumbContext(av, frames, n, done)
+	return populateThumbContext(av, frames, n, samples, done)
 }
 
-func populateThumbContext(av *AVContext, frames chan *C.AVFrame, n C.int, done <-chan struct{}) error {
+func populateThumbContext(
+	av *AVContext, frames chan *C.AVFrame, n C.int, samples []time.Duration, done <-chan struct{},
+) error {
 	pkt := C.create_packet()
 	if pkt == nil {
 		return avError(C.int(ErrNoMem))
@@ -377,10 +809,13 @@ func populateThumbContext(av *AVContext, frames chan *C.AVFrame, n C.int, done <
 	var frame *C.AVFrame
 	var err C.int
 	for i := C.int(1); i < n; i++ {
-		err = C.obtain_next_frame(av.formatContext, av.codecContext, av.stream.index, pkt, &frame)
+		err = obtainFrame(av, pkt, &frame, samples, i)
 		if err < 0 {
 			break
 		}
+		if av.seekedEnd > 0 && frame.pts != C.AV_NOPTS_VALUE && ptsToDuration(av, frame.pts) >= av.seekedEnd {
+			break
+		}
 		incrementDuration(av, frame, i)
 		frames <- frame
 		frame = nil
@@ -405,17 +840,72 @@ func populateThumbContext(av *AVContext, frames chan *C.AVFrame, n C.int, done <
 	}
 	if av.selectedIndex < 0 {
 		av.selectedIndex = C.find_best_frame_index(av.thumbContext)
+		if av.selectedIndex < 0 {
+			return ErrFrameRejected
+		}
 	}
 	return nil
 }
 
-func convertFrameToRGB(av *AVContext, bands int) error {
-	var alpha int
+// scoreFrame scores frame with the custom frame scorer,
+// frame that cannot be converted is rejected
+func scoreFrame(av *AVContext, n C.int, frame *C.AVFrame) {
+	var score float64
+	var ok bool
+	opts := convertOptions(av, 4, 0, 0)
+	if rgb := C.convert_frame_to_rgb(frame, &opts); rgb != nil {
+		f := &Frame{
+			Index:  int(n),
+			Width:  int(rgb.width),
+			Height: int(rgb.height),
+		}
+		if frame.pts != C.AV_NOPTS_VALUE {
+			f.Duration = ptsToDuration(av, frame.pts)
+		}
+		f.Buf = C.GoBytes(unsafe.Pointer(rgb.data[0]), C.int(f.Width*f.Height*4))
+		C.av_frame_free(&rgb)
+		score, ok = av.scorer.Score(f)
+	}
+	var rejected C.int
+	if !ok {
+		rejected = 1
+	}
+	C.set_frame_score(av.thumbContext, n, C.double(score), rejected)
+}
+
+// convertOptions options to convert frame to RGB or RGBA of the specified dimensions,
+// or the frame dimensions if 0
+func convertOptions(av *AVContext, bands, width, height int) C.ConvertOptions {
+	opts := C.ConvertOptions{
+		width:           C.int(width),
+		height:          C.int(height),
+		tonemap:         C.int(av.toneMapping),
+		color_primaries: C.int(av.stream.codecpar.color_primaries),
+		color_trc:       C.int(av.stream.codecpar.color_trc),
+		deinterlace:     C.int(av.deinterlace),
+	}
 	if bands == 4 {
-		alpha = 1
+		opts.alpha = 1
 	}
-	av.frame = C.convert_frame_to_rgb(
-		C.select_frame(av.thumbContext, av.selectedIndex), C.int(alpha))
+	if av.contentLight != nil && av.contentLight.MaxCLL > 0 {
+		opts.peak = C.double(av.contentLight.MaxCLL)
+	} else if av.masteringDisplay != nil && av.masteringDisplay.MaxLuminance > 0 {
+		opts.peak = C.double(av.masteringDisplay.MaxLuminance)
+	}
+	return opts
+}
+
+func convertFrameToRGB(av *AVContext, bands int) error {
+	width, height := av.exportWidth, av.exportHeight
+	if (width == 0 || height == 0) && av.codedWidth > 0 {
+		width, height = av.width, av.height
+	}
+	opts := convertOptions(av, bands, width, height)
+	opts.depth = C.int(av.exportDepth)
+	if av.frame != nil {
+		C.av_frame_free(&av.frame)
+	}
+	av.frame = C.convert_frame_to_rgb(C.select_frame(av.thumbContext, av.selectedIndex), &opts)
 	if av.frame == nil {
 		return ErrNoMem
 	}
@@ -426,7 +916,75 @@ func exportBuffer(av *AVContext, bands int) ([]byte, error) {
 	if av.frame == nil {
 		return nil, ErrInvalidData
 	}
-	size := av.height * av.width * bands
+	size := int(av.frame.height) * int(av.frame.width) * bands * sampleSize(av)
 	buf := C.GoBytes(unsafe.Pointer(av.frame.data[0]), C.int(size))
 	return buf, nil
 }
+
+// sampleSize bytes per channel of the exported frames
+func sampleSize(av *AVContext) int {
+	if av.exportDepth == 16 {
+		return 2
+	}
+	return 1
+}
+
+// seekFrame seeks to keyframe before the specified duration,
+// then decodes until the frame at or after the duration, or the last frame available
+func seekFrame(av *AVContext, pkt *C.AVPacket, ts time.Duration) (*C.AVFrame, error) {
+	if err := seekDuration(av, ts); err != nil {
+		return nil, err
+	}
+	var frame, last *C.AVFrame
+	var err C.int
+	for {
+		err = C.obtain_next_frame(av.formatContext, av.codecContext, av.stream.index, pkt, &frame)
+		if err < 0 {
+			break
+		}
+		frame, last = last, frame
+		if last.pts == C.AV_NOPTS_VALUE || ptsToDuration(av, last.pts) >= ts {
+			break
+		}
+	}
+	if frame != nil {
+		C.av_frame_free(&frame)
+	}
+	if last == nil {
+		return nil, avError(err)
+	}
+	return last, nil
+}
+
+func exportFrames(av *AVContext, durations []time.Duration, bands int, fn func(i int, buf []byte) error) error {
+	pkt := C.create_packet()
+	if pkt == nil {
+		return avError(C.int(ErrNoMem))
+	}
+	defer C.av_packet_free(&pkt)
+
+	width, height := av.width, av.height
+	if av.exportWidth > 0 && av.exportHeight > 0 {
+		width, height = av.exportWidth, av.exportHeight
+	}
+	opts := convertOptions(av, bands, width, height)
+	opts.depth = C.int(av.exportDepth)
+	for i, ts := range durations {
+		frame, err := seekFrame(av, pkt, ts)
+		if err != nil {
+			return err
+		}
+		rgb := C.convert_frame_to_rgb(frame, &opts)
+		C.av_frame_free(&frame)
+		if rgb == nil {
+			return ErrNoMem
+		}
+		size := int(rgb.height) * int(rgb.width) * bands * sampleSize(av)
+		buf := C.GoBytes(unsafe.Pointer(rgb.data[0]), C.int(size))
+		C.av_frame_free(&rgb)
+		if err = fn(i, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
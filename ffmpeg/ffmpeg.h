@@ -12,6 +12,7 @@
 #include <libavutil/intreadwrite.h>
 #include <libavutil/imgutils.h>
 #include <libavutil/display.h>
+#include <libavutil/mastering_display_metadata.h>
 
 #define BUFFER_SIZE 1 << 12
 #define READ_PACKET_FLAG 1
@@ -19,20 +20,68 @@
 #define HAS_VIDEO_STREAM 1
 #define HAS_AUDIO_STREAM 2
 #define ERR_TOO_BIG FFERRTAG('H','M','M','M')
+#define ERR_REJECTED FFERRTAG('R','J','C','T')
+#define ERR_CHAPTER_NOT_FOUND FFERRTAG('C','H','A','P')
+#define SCENE_CUT_THRESHOLD 0.3
+#define SCENE_CUT_MARGIN 2
+#define STRATEGY_RMSE 0
+#define STRATEGY_SHARPNESS 1
+#define STRATEGY_ENTROPY 2
+#define STRATEGY_COLORFULNESS 3
+#define STRATEGY_CUSTOM 4
+#define SHARPNESS_SAMPLE_SIZE 640
+#define CROP_LIMIT 24
+#define CROP_SAMPLE_SIZE 640
+#define TONEMAP_HABLE 0
+#define TONEMAP_MOBIUS 1
+#define TONEMAP_BT2390 2
+#define TONEMAP_NONE 3
+#define TONEMAP_LUT_SIZE 4096
+#define SDR_WHITE_NITS 203
+#define PQ_PEAK_NITS 1000
+#define HLG_PEAK_NITS 1000
+#define PQ_M1 0.1593017578125
+#define PQ_M2 78.84375
+#define PQ_C1 0.8359375
+#define PQ_C2 18.8515625
+#define PQ_C3 18.6875
+#define HLG_A 0.17883277
+#define HLG_B 0.28466892
+#define HLG_C 0.55991073
+#define DEINTERLACE_AUTO 0
+#define DEINTERLACE_ON 1
+#define DEINTERLACE_OFF 2
 
 struct thumb_frame {
     AVFrame *frame;
     int *hist;
+    double score;
+    int rejected;
 };
 
 typedef struct ThumbContext {
     int n, max_frames;
+    int scene;
+    int strategy;
+    int crop;
+    int crop_left, crop_top, crop_right, crop_bottom;
     struct thumb_frame *frames;
     double *median;
     const AVPixFmtDescriptor *desc;
     size_t hist_size;
 } ThumbContext;
 
+typedef struct ConvertOptions {
+    int alpha;
+    int depth;
+    int width, height;
+    int tonemap;
+    double peak;
+    int color_primaries;
+    int color_trc;
+    int deinterlace;
+} ConvertOptions;
+
 int allocate_format_context(AVFormatContext **fmt_ctx);
 
 int create_format_context(AVFormatContext *fmt_ctx, void* opaque, int callbacks);
@@ -41,11 +90,11 @@ void free_format_context(AVFormatContext *fmt_ctx);
 
 void get_metadata(AVFormatContext *fmt_ctx, char **artist, char **title);
 
-int find_streams(AVFormatContext *fmt_ctx, AVStream **video_stream, int *orientation);
+int find_streams(AVFormatContext *fmt_ctx, AVStream **video_stream, int *orientation, int wanted_stream);
 
-int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx);
+int create_codec_context(AVStream *video_stream, AVCodecContext **dec_ctx, int width, int height);
 
-AVFrame *convert_frame_to_rgb(AVFrame *frame, int alpha);
+AVFrame *convert_frame_to_rgb(AVFrame *frame, const ConvertOptions *opts);
 
 AVPacket *create_packet();
 
@@ -63,6 +112,8 @@ void populate_frame(ThumbContext *thumb_ctx, int n, AVFrame *frame);
 
 void populate_histogram(ThumbContext *thumb_ctx, int n, AVFrame *frame);
 
+void set_frame_score(ThumbContext *thumb_ctx, int n, double score, int rejected);
+
 extern int goPacketRead(void *opaque, uint8_t *buf, int buf_size);
 
 extern int64_t goPacketSeek(void *opaque, int64_t seek, int whence);
@@ -1,9 +1,11 @@
 package ffmpeg
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"reflect"
 	"strings"
@@ -30,10 +32,34 @@ var files = []string{
 
 var noVideo = []string{
 	"no_cover.mp3",
+	"tags.mp3",
 }
 
 var baseDir = "../testdata/"
 
+// openAVContext loads AVContext of the test file, closed on test cleanup
+func openAVContext(t *testing.T, filename string, options ...Option) (*AVContext, error) {
+	t.Helper()
+	path := baseDir + filename
+	reader, err := os.Open(path)
+	require.NoError(t, err)
+	stats, err := os.Stat(path)
+	require.NoError(t, err)
+	av, err := LoadAVContext(reader, stats.Size(), options...)
+	if err == nil {
+		t.Cleanup(av.Close)
+	}
+	return av, err
+}
+
+// loadAVContext loads AVContext of the test file that must succeed, closed on test cleanup
+func loadAVContext(t *testing.T, filename string, options ...Option) *AVContext {
+	t.Helper()
+	av, err := openAVContext(t, filename, options...)
+	require.NoError(t, err)
+	return av
+}
+
 func TestAVContext(t *testing.T) {
 	vips.Startup(nil)
 	SetFFmpegLogLevel(AVLogDebug)
@@ -115,6 +141,608 @@ func TestAVContext(t *testing.T) {
 	}
 }
 
+func TestExportFrames(t *testing.T) {
+	av := loadAVContext(t, "everybody-betray-me.mkv")
+	meta := av.Metadata()
+	bufs, err := av.ExportFrames([]time.Duration{0, time.Second, 3 * time.Second}, 3)
+	require.NoError(t, err)
+	require.Len(t, bufs, 3)
+	for _, buf := range bufs {
+		assert.Len(t, buf, meta.Width*meta.Height*3)
+	}
+	assert.False(t, reflect.DeepEqual(bufs[0], bufs[2]))
+
+	av = loadAVContext(t, "everybody-betray-me.mkv")
+	require.NoError(t, av.SetExportSize(meta.Width/4, meta.Height/4))
+	bufs, err = av.ExportFrames([]time.Duration{0, time.Second}, 3)
+	require.NoError(t, err)
+	require.Len(t, bufs, 2)
+	for _, buf := range bufs {
+		assert.Len(t, buf, meta.Width/4*meta.Height/4*3)
+	}
+}
+
+func TestSceneDetection(t *testing.T) {
+	for _, filename := range []string{"everybody-betray-me.mkv", "macabre.mp4"} {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename, WithSceneDetection(true))
+			require.NoError(t, av.ProcessFrames(-1))
+			assert.True(t, av.selectedIndex >= 0 && av.selectedIndex < av.thumbContext.n)
+			meta := av.Metadata()
+			buf, err := av.Export(3)
+			require.NoError(t, err)
+			assert.Len(t, buf, meta.Width*meta.Height*3)
+		})
+	}
+}
+
+func TestSceneDetectionShots(t *testing.T) {
+	// 6 frames of blue, 8 frames of yellow, then 6 frames of blue again
+	av := loadAVContext(t, "scenes.mkv")
+	require.NoError(t, av.ProcessFrames(-1))
+	require.Equal(t, 20, int(av.thumbContext.n))
+	assert.True(t, av.selectedIndex < 6 || av.selectedIndex >= 14, av.selectedIndex)
+
+	av = loadAVContext(t, "scenes.mkv", WithSceneDetection(true))
+	require.NoError(t, av.ProcessFrames(-1))
+	// inside the longest shot, skipping the frames next to the cuts
+	assert.True(t, av.selectedIndex >= 6+2 && av.selectedIndex < 14-2, av.selectedIndex)
+	meta := av.Metadata()
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, meta.Width*meta.Height*3)
+	assert.Greater(t, int(buf[0]), 200)
+	assert.Greater(t, int(buf[1]), 200)
+	assert.Less(t, int(buf[2]), 100)
+}
+
+func TestStrategy(t *testing.T) {
+	// frames of flat gray, blurred gradient, sharp checkerboard, then saturated red and blue halves
+	for strategy, index := range map[Strategy]int{
+		StrategySharpness:    2,
+		StrategyEntropy:      1,
+		StrategyColorfulness: 3,
+	} {
+		t.Run(fmt.Sprintf("%d", strategy), func(t *testing.T) {
+			av := loadAVContext(t, "strategy.mkv", WithStrategy(strategy))
+			require.NoError(t, av.ProcessFrames(-1))
+			require.Equal(t, 4, int(av.thumbContext.n))
+			assert.Equal(t, index, int(av.selectedIndex))
+			meta := av.Metadata()
+			buf, err := av.Export(3)
+			require.NoError(t, err)
+			require.Len(t, buf, meta.Width*meta.Height*3)
+			if strategy == StrategyColorfulness {
+				// red on the left
+				assert.Greater(t, int(buf[0]), 200)
+				assert.Less(t, int(buf[1]), 50)
+			}
+		})
+	}
+}
+
+func TestFrameScorer(t *testing.T) {
+	load := func(t *testing.T, filename string, scorer FrameScorer) *AVContext {
+		return loadAVContext(t, filename, WithFrameScorer(scorer))
+	}
+	t.Run("highest score", func(t *testing.T) {
+		var frames []*Frame
+		av := load(t, "everybody-betray-me.mkv", FrameScorerFunc(func(frame *Frame) (float64, bool) {
+			frames = append(frames, frame)
+			// prefers the frame nearest to 1s
+			return -math.Abs(frame.Duration.Seconds() - 1), true
+		}))
+		require.NoError(t, av.ProcessFrames(-1))
+		require.NotEmpty(t, frames)
+		meta := av.Metadata()
+		best := 0
+		for i, frame := range frames {
+			assert.Equal(t, i, frame.Index)
+			assert.Equal(t, meta.Width, frame.Width)
+			assert.Equal(t, meta.Height, frame.Height)
+			assert.Len(t, frame.Buf, frame.Width*frame.Height*4)
+			if math.Abs(frame.Duration.Seconds()-1) < math.Abs(frames[best].Duration.Seconds()-1) {
+				best = i
+			}
+		}
+		assert.Equal(t, best, int(av.selectedIndex))
+		buf, err := av.Export(3)
+		require.NoError(t, err)
+		assert.Len(t, buf, meta.Width*meta.Height*3)
+	})
+	t.Run("rejected", func(t *testing.T) {
+		av := load(t, "everybody-betray-me.mkv", FrameScorerFunc(func(frame *Frame) (float64, bool) {
+			return 1, frame.Index == 3
+		}))
+		require.NoError(t, av.ProcessFrames(-1))
+		assert.Equal(t, 3, int(av.selectedIndex))
+	})
+	t.Run("all rejected", func(t *testing.T) {
+		av := load(t, "alpha-webm.webm", FrameScorerFunc(func(frame *Frame) (float64, bool) {
+			return 1, false
+		}))
+		assert.Equal(t, ErrFrameRejected, av.ProcessFrames(-1))
+		_, err := av.Export(4)
+		assert.Equal(t, ErrFrameRejected, err)
+	})
+	t.Run("selected frame not scored", func(t *testing.T) {
+		av := load(t, "everybody-betray-me.mkv", FrameScorerFunc(func(frame *Frame) (float64, bool) {
+			return 0, false
+		}))
+		require.NoError(t, av.SelectFrame(2))
+		_, err := av.Export(4)
+		require.NoError(t, err)
+	})
+}
+
+func TestCropDetection(t *testing.T) {
+	for _, filename := range files {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename, WithCropDetection(true))
+			assert.Nil(t, av.Metadata().Crop)
+			require.NoError(t, av.ProcessFrames(-1))
+			meta := av.Metadata()
+			if crop := meta.Crop; crop != nil {
+				assert.True(t, crop.Left >= 0 && crop.Left < crop.Right && crop.Right <= meta.Width)
+				assert.True(t, crop.Top >= 0 && crop.Top < crop.Bottom && crop.Bottom <= meta.Height)
+			}
+		})
+	}
+	// 64x48 of black bars of 8 rows at top and bottom
+	av := loadAVContext(t, "letterbox.mkv", WithCropDetection(true))
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.Equal(t, &Crop{Left: 0, Top: 8, Right: 64, Bottom: 40}, av.Metadata().Crop)
+}
+
+func TestSampling(t *testing.T) {
+	// keyframes at 0, 467ms, 4.805s and 5.005s of 7.407s
+	av := loadAVContext(t, "everybody-betray-me.mkv", WithSampling(8), WithSceneDetection(true))
+	samples := keyframeSamples(av)
+	require.Len(t, samples, 8)
+	for i, ts := range samples {
+		// spread evenly within the margins at start and end
+		assert.True(t, ts > 370*time.Millisecond && ts < 7037*time.Millisecond)
+		if i > 0 {
+			assert.InDelta(t, float64(samples[1]-samples[0]), float64(ts-samples[i-1]), float64(time.Millisecond))
+		}
+	}
+	require.NoError(t, av.ProcessFrames(-1))
+	// single keyframe decoded per sample point instead of the consecutive frames
+	assert.Equal(t, 8, int(av.thumbContext.n))
+	assert.Zero(t, int(av.thumbContext.scene))
+	assert.Equal(t, 5005*time.Millisecond, av.availableDuration)
+
+	// sampling from the seeked duration
+	av = loadAVContext(t, "everybody-betray-me.mkv", WithSampling(4))
+	require.NoError(t, av.SeekDuration(3*time.Second))
+	samples = keyframeSamples(av)
+	require.Len(t, samples, 4)
+	assert.Greater(t, samples[0], 3*time.Second)
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.Equal(t, 4, int(av.thumbContext.n))
+}
+
+func TestFastDecode(t *testing.T) {
+	// keyframes at 0, 467ms, 4.805s and 5.005s
+	av := loadAVContext(t, "everybody-betray-me.mkv", WithFastDecode(true))
+	meta := av.Metadata()
+	assert.True(t, meta.FastDecode)
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.Equal(t, 4, int(av.thumbContext.n))
+	assert.Equal(t, 5005*time.Millisecond, av.availableDuration)
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	assert.Len(t, buf, meta.Width*meta.Height*3)
+
+	// all frames decoded otherwise
+	av = loadAVContext(t, "everybody-betray-me.mkv")
+	assert.False(t, av.Metadata().FastDecode)
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.Greater(t, int(av.thumbContext.n), 4)
+}
+
+func TestExportSize(t *testing.T) {
+	full := loadAVContext(t, "everybody-betray-me.mkv")
+	require.NoError(t, full.SelectFrame(1))
+	meta := full.Metadata()
+	expected, err := full.Export(3)
+	require.NoError(t, err)
+
+	av := loadAVContext(t, "everybody-betray-me.mkv")
+	require.NoError(t, av.SelectFrame(1))
+	require.NoError(t, av.SetExportSize(meta.Width/4, meta.Height/4))
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, meta.Width/4*meta.Height/4*3)
+	// metadata of the source dimensions as is
+	assert.Equal(t, meta, av.Metadata())
+	// same frame of average color preserved by the downscale
+	mean := func(buf []byte) (sum [3]float64) {
+		for i, v := range buf {
+			sum[i%3] += float64(v) * 3 / float64(len(buf))
+		}
+		return
+	}
+	expectedMean, actualMean := mean(expected), mean(buf)
+	for i := range expectedMean {
+		assert.InDelta(t, expectedMean[i], actualMean[i], 2)
+	}
+}
+
+func TestExportDepth(t *testing.T) {
+	for _, filename := range files {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename)
+			meta := av.Metadata()
+			buf, err := av.Export(4)
+			require.NoError(t, err)
+			av.SetExportDepth(16)
+			buf16, err := av.Export(4)
+			require.NoError(t, err)
+			require.Len(t, buf16, meta.Width*meta.Height*4*2)
+			for i := 0; i < len(buf); i += len(buf) / 16 {
+				sample := binary.NativeEndian.Uint16(buf16[i*2:])
+				assert.InDelta(t, int(buf[i]), int(sample>>8), 2)
+			}
+		})
+	}
+}
+
+func TestToneMappingHDR(t *testing.T) {
+	// 10-bit BT.2020 bands of black, reference white of 203 nits and highlight of 1000 nits
+	for filename, transfer := range map[string]string{
+		"hdr-pq.mkv":  "smpte2084",
+		"hdr-hlg.mkv": "arib-std-b67",
+	} {
+		for _, toneMapping := range []ToneMapping{ToneMapHable, ToneMapMobius, ToneMapBT2390, ToneMapNone} {
+			t.Run(fmt.Sprintf("%s-%d", filename, toneMapping), func(t *testing.T) {
+				av := loadAVContext(t, filename, WithToneMapping(toneMapping))
+				meta := av.Metadata()
+				assert.True(t, meta.IsHDR)
+				assert.Equal(t, transfer, meta.ColorTransfer)
+				assert.Equal(t, "bt2020", meta.ColorPrimaries)
+				if transfer == "smpte2084" {
+					require.NotNil(t, meta.MasteringDisplay)
+					assert.Equal(t, 1000.0, meta.MasteringDisplay.MaxLuminance)
+					assert.Equal(t, &ContentLight{MaxCLL: 1000, MaxFALL: 400}, meta.ContentLight)
+				}
+				buf, err := av.Export(3)
+				require.NoError(t, err)
+				require.Len(t, buf, meta.Width*meta.Height*3)
+				row := buf[meta.Height/2*meta.Width*3:]
+				var luma [3]int
+				for i := range luma {
+					p := row[(i*meta.Width/3+meta.Width/6)*3:]
+					luma[i] = (int(p[0])*2126 + int(p[1])*7152 + int(p[2])*722) / 10000
+				}
+				if toneMapping == ToneMapNone {
+					if transfer == "smpte2084" {
+						// PQ signal as is is washed out
+						assert.Less(t, luma[2], 200)
+					}
+					return
+				}
+				// full SDR range of black, bright reference white and peak highlight
+				assert.LessOrEqual(t, luma[0], 5)
+				assert.Greater(t, luma[1], 150)
+				assert.Greater(t, luma[2], 250)
+				assert.Less(t, luma[1], luma[2])
+			})
+		}
+	}
+}
+
+func TestColorRange(t *testing.T) {
+	require.NoError(t, os.MkdirAll(baseDir+"golden/export", 0755))
+	// top half of luma 16, bottom half of luma 235, neutral chroma
+	for filename, levels := range map[string][2]int{
+		"range-limited.y4m": {0, 255},
+		"range-full.y4m":    {16, 235},
+	} {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename)
+			meta := av.Metadata()
+			buf, err := av.Export(3)
+			require.NoError(t, err)
+			require.Len(t, buf, meta.Width*meta.Height*3)
+			top := buf[(meta.Height/4*meta.Width+meta.Width/2)*3:]
+			bottom := buf[(meta.Height*3/4*meta.Width+meta.Width/2)*3:]
+			for i := 0; i < 3; i++ {
+				assert.InDelta(t, levels[0], int(top[i]), 1)
+				assert.InDelta(t, levels[1], int(bottom[i]), 1)
+			}
+			img, err := vips.NewImageFromMemory(buf, meta.Width, meta.Height, 3)
+			require.NoError(t, err)
+			buf, err = img.JpegsaveBuffer(nil)
+			require.NoError(t, err)
+			goldenFile := baseDir + "golden/export/" + filename + ".jpg"
+			if curr, err := os.ReadFile(goldenFile); err == nil {
+				assert.True(t, reflect.DeepEqual(curr, buf))
+			} else {
+				require.NoError(t, os.WriteFile(goldenFile, buf, 0666))
+			}
+		})
+	}
+}
+
+func TestColorSpace(t *testing.T) {
+	// BT.709 red of luma 63, chroma 102 and 240, decoded as of BT.601 gives red of 233
+	av := loadAVContext(t, "bt709.mkv")
+	meta := av.Metadata()
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, meta.Width*meta.Height*3)
+	for _, i := range []int{0, len(buf)/2 + meta.Width/2*3, len(buf) - 3} {
+		assert.InDelta(t, 255, int(buf[i]), 2)
+		assert.InDelta(t, 0, int(buf[i+1]), 2)
+		assert.InDelta(t, 0, int(buf[i+2]), 2)
+	}
+}
+
+func TestDisplaySize(t *testing.T) {
+	// clockwise rotation for display, of the counterclockwise display matrix rotation in file name
+	for filename, rotation := range map[string]int{
+		"schizo_0.mp4":   0,
+		"schizo_90.mp4":  270,
+		"schizo_180.mp4": 180,
+		"schizo_270.mp4": 90,
+	} {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename)
+			meta := av.Metadata()
+			assert.Equal(t, rotation, meta.Rotation)
+			if rotation == 90 || rotation == 270 {
+				assert.Equal(t, meta.Height, meta.DisplayWidth)
+				assert.Equal(t, meta.Width, meta.DisplayHeight)
+			} else {
+				assert.Equal(t, meta.Width, meta.DisplayWidth)
+				assert.Equal(t, meta.Height, meta.DisplayHeight)
+			}
+		})
+	}
+	for orientation, rotation := range []int{0, 0, 0, 180, 180, 90, 90, 270, 270} {
+		assert.Equal(t, rotation, orientationRotation(orientation))
+	}
+}
+
+func TestDisplayMirrored(t *testing.T) {
+	// 64x32 quadrants of red, green, blue and white, of display matrix rotated 90 and flipped horizontally
+	av := loadAVContext(t, "mirrored.mp4")
+	meta := av.Metadata()
+	assert.Equal(t, 5, meta.Orientation)
+	assert.Equal(t, 90, meta.Rotation)
+	assert.Equal(t, 64, meta.Width)
+	assert.Equal(t, 32, meta.Height)
+	assert.Equal(t, 32, meta.DisplayWidth)
+	assert.Equal(t, 64, meta.DisplayHeight)
+	width, height := meta.FrameSize()
+	assert.Equal(t, 64, width)
+	assert.Equal(t, 32, height)
+	// exported as is, orientation applied by the processor
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, width*height*3)
+	for _, tt := range []struct {
+		x, y  int
+		color [3]int
+	}{
+		{x: 0, y: 0, color: [3]int{255, 0, 0}},
+		{x: width - 1, y: 0, color: [3]int{0, 255, 0}},
+		{x: 0, y: height - 1, color: [3]int{0, 0, 255}},
+		{x: width - 1, y: height - 1, color: [3]int{255, 255, 255}},
+	} {
+		p := buf[(tt.y*width+tt.x)*3:]
+		for i, c := range tt.color {
+			assert.InDelta(t, c, int(p[i]), 8, "%d,%d", tt.x, tt.y)
+		}
+	}
+}
+
+func TestAnamorphic(t *testing.T) {
+	// 48x32 of 4:3 sample aspect ratio, left half black and right half white
+	av := loadAVContext(t, "anamorphic.y4m")
+	meta := av.Metadata()
+	assert.Equal(t, 48, meta.Width)
+	assert.Equal(t, 32, meta.Height)
+	assert.Equal(t, "4:3", meta.SAR)
+	assert.Equal(t, "2:1", meta.DAR)
+	assert.Equal(t, 64, meta.DisplayWidth)
+	assert.Equal(t, 32, meta.DisplayHeight)
+	width, height := meta.FrameSize()
+	assert.Equal(t, 64, width)
+	assert.Equal(t, 32, height)
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, 64*32*3)
+	row := buf[16*64*3:]
+	assert.InDelta(t, 0, int(row[16*3]), 1)
+	assert.InDelta(t, 255, int(row[48*3]), 1)
+	bufs, err := av.ExportFrames([]time.Duration{0}, 3)
+	require.NoError(t, err)
+	require.Len(t, bufs, 1)
+	assert.Len(t, bufs[0], 64*32*3)
+
+	for _, filename := range files {
+		meta := loadAVContext(t, filename).Metadata()
+		assert.Equal(t, "1:1", meta.SAR, filename)
+		assert.NotEmpty(t, meta.DAR, filename)
+		width, height := meta.FrameSize()
+		assert.Equal(t, meta.Width, width, filename)
+		assert.Equal(t, meta.Height, height, filename)
+	}
+	meta = loadAVContext(t, "no_cover.mp3").Metadata()
+	assert.Empty(t, meta.SAR)
+	assert.Empty(t, meta.DAR)
+}
+
+func TestDeinterlace(t *testing.T) {
+	// 32x32 top field first, lines of alternate luma 235 and 16
+	for _, tt := range []struct {
+		deinterlace Deinterlace
+		combing     bool
+	}{
+		{deinterlace: DeinterlaceAuto},
+		{deinterlace: DeinterlaceOn},
+		{deinterlace: DeinterlaceOff, combing: true},
+	} {
+		t.Run(fmt.Sprintf("%d", tt.deinterlace), func(t *testing.T) {
+			av := loadAVContext(t, "interlaced.y4m", WithDeinterlace(tt.deinterlace))
+			meta := av.Metadata()
+			assert.True(t, meta.Interlaced)
+			buf, err := av.Export(3)
+			require.NoError(t, err)
+			require.Len(t, buf, meta.Width*meta.Height*3)
+			even := int(buf[16*meta.Width*3])
+			odd := int(buf[17*meta.Width*3])
+			if tt.combing {
+				assert.Greater(t, even-odd, 200)
+			} else {
+				assert.InDelta(t, even, odd, 2)
+			}
+		})
+	}
+}
+
+func TestStreamSelection(t *testing.T) {
+	// default 64x48 blue track of eng "Main", and 32x24 yellow track of fre "Commentary"
+	av := loadAVContext(t, "streams.mkv")
+	meta := av.Metadata()
+	require.Len(t, meta.Streams, 2)
+	for i, stream := range meta.Streams {
+		assert.Equal(t, i, stream.Index)
+		assert.Equal(t, "video", stream.CodecType)
+		assert.Equal(t, "ffv1", stream.CodecName)
+		assert.Equal(t, "yuv420p", stream.PixelFormat)
+		assert.Equal(t, 8, stream.BitDepth)
+	}
+	assert.Equal(t, "eng", meta.Streams[0].Language)
+	assert.Equal(t, "Main", meta.Streams[0].Title)
+	assert.True(t, meta.Streams[0].Default)
+	assert.True(t, meta.Streams[0].Selected)
+	assert.Equal(t, "fre", meta.Streams[1].Language)
+	assert.Equal(t, "Commentary", meta.Streams[1].Title)
+	assert.False(t, meta.Streams[1].Selected)
+	assert.Equal(t, 64, meta.Width)
+	assert.Equal(t, 48, meta.Height)
+	buf, err := av.Export(3)
+	require.NoError(t, err)
+	require.Len(t, buf, 64*48*3)
+	assert.InDeltaSlice(t, []int{0, 0, 255}, []int{int(buf[0]), int(buf[1]), int(buf[2])}, 8)
+
+	for _, option := range []Option{WithStreamIndex(1), WithStreamMatch("fre"), WithStreamMatch("Commentary")} {
+		av := loadAVContext(t, "streams.mkv", option)
+		meta := av.Metadata()
+		require.Len(t, meta.Streams, 2)
+		assert.False(t, meta.Streams[0].Selected)
+		assert.True(t, meta.Streams[1].Selected)
+		assert.Equal(t, 32, meta.Width)
+		assert.Equal(t, 24, meta.Height)
+		buf, err := av.Export(3)
+		require.NoError(t, err)
+		require.Len(t, buf, 32*24*3)
+		assert.InDeltaSlice(t, []int{255, 255, 0}, []int{int(buf[0]), int(buf[1]), int(buf[2])}, 8)
+	}
+
+	_, err = openAVContext(t, "streams.mkv", WithStreamIndex(99))
+	assert.Equal(t, ErrStreamNotFound, err)
+	_, err = openAVContext(t, "streams.mkv", WithStreamMatch("nonexistent"))
+	assert.Equal(t, ErrStreamNotFound, err)
+	_, err = openAVContext(t, "everybody-betray-me.mkv", WithStreamIndex(1))
+	assert.Equal(t, ErrStreamNotFound, err)
+}
+
+func TestCover(t *testing.T) {
+	// 64x48 blue video, of 32x32 green JPEG cover.jpg attachment or covr atom
+	for _, filename := range []string{"cover.mkv", "cover.mp4"} {
+		t.Run(filename, func(t *testing.T) {
+			av := loadAVContext(t, filename)
+			meta := av.Metadata()
+			assert.True(t, meta.HasCover)
+			require.Len(t, meta.Streams, 2)
+			assert.True(t, meta.Streams[0].Selected)
+			assert.True(t, meta.Streams[1].AttachedPic)
+			assert.Nil(t, av.AttachedPicture())
+			buf, err := av.Export(3)
+			require.NoError(t, err)
+			require.Len(t, buf, 64*48*3)
+			assert.InDeltaSlice(t, []int{0, 0, 255}, []int{int(buf[0]), int(buf[1]), int(buf[2])}, 8)
+
+			// embedded picture preferred over the video
+			av = loadAVContext(t, filename, WithCover("front"))
+			meta = av.Metadata()
+			require.Len(t, meta.Streams, 2)
+			assert.False(t, meta.Streams[0].Selected)
+			assert.True(t, meta.Streams[1].Selected)
+			assert.Equal(t, 32, meta.Width)
+			assert.Equal(t, 32, meta.Height)
+			pic := av.AttachedPicture()
+			require.NotEmpty(t, pic)
+			assert.Equal(t, []byte{0xff, 0xd8}, pic[:2])
+			buf, err = av.Export(3)
+			require.NoError(t, err)
+			require.Len(t, buf, 32*32*3)
+			assert.InDeltaSlice(t, []int{13, 238, 14}, []int{int(buf[0]), int(buf[1]), int(buf[2])}, 8)
+		})
+	}
+	// video as is without embedded picture
+	av := loadAVContext(t, "everybody-betray-me.mkv", WithCover("front"))
+	assert.False(t, av.Metadata().HasCover)
+	assert.Nil(t, av.AttachedPicture())
+}
+
+func TestChapters(t *testing.T) {
+	av := loadAVContext(t, "chapters.mkv")
+	assert.Equal(t, []Chapter{
+		{ID: 1, Start: 0, End: 3000, Title: "Intro"},
+		{ID: 2, Start: 3000, End: 7407, Title: "Betrayal"},
+	}, av.Metadata().Chapters)
+	require.NoError(t, av.SeekChapter(2))
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.True(t, av.thumbContext.n > 1)
+	assert.True(t, av.availableDuration >= 3*time.Second && av.availableDuration < 7407*time.Millisecond)
+
+	av = loadAVContext(t, "chapters.mkv")
+	require.NoError(t, av.SeekChapter(1))
+	require.NoError(t, av.ProcessFrames(-1))
+	assert.True(t, av.availableDuration < 3*time.Second)
+
+	assert.Equal(t, ErrChapterNotFound, loadAVContext(t, "chapters.mkv").SeekChapter(3))
+	assert.Equal(t, ErrChapterNotFound, loadAVContext(t, "chapters.mkv").SeekChapter(0))
+
+	av = loadAVContext(t, "everybody-betray-me.mkv")
+	assert.Empty(t, av.Metadata().Chapters)
+	assert.Equal(t, ErrChapterNotFound, av.SeekChapter(1))
+}
+
+func TestAudioTags(t *testing.T) {
+	av := loadAVContext(t, "tags.mp3")
+	meta := av.Metadata()
+	assert.Equal(t, "No Cover", meta.Title)
+	assert.Equal(t, "imagorvideo", meta.Artist)
+	assert.Equal(t, "Test Fixtures", meta.Album)
+	assert.Equal(t, "Various Artists", meta.AlbumArtist)
+	assert.Equal(t, "Electronic", meta.Genre)
+	assert.Equal(t, "3/12", meta.Track)
+	assert.Equal(t, "1/2", meta.Disc)
+	assert.Equal(t, "2024-05-01", meta.Date)
+	assert.Equal(t, "Test Composer", meta.Composer)
+	assert.Equal(t, "La la la", meta.Lyrics)
+	assert.Equal(t, "calm", meta.Tags["mood"])
+	for _, key := range []string{"title", "artist", "album", "album_artist", "track", "date", "lyrics-eng"} {
+		assert.NotContains(t, meta.Tags, key)
+	}
+	require.NotNil(t, meta.Audio)
+	assert.Equal(t, "mp3", meta.Audio.Codec)
+	assert.NotZero(t, meta.Audio.SampleRate)
+	assert.NotZero(t, meta.Audio.Channels)
+	assert.NotEmpty(t, meta.Audio.ChannelLayout)
+	assert.NotZero(t, meta.Audio.BitRate)
+	require.Len(t, meta.Streams, 1)
+	assert.Equal(t, "audio", meta.Streams[0].CodecType)
+	assert.Equal(t, meta.Audio.SampleRate, meta.Streams[0].SampleRate)
+	assert.Equal(t, meta.Audio.ChannelLayout, meta.Streams[0].ChannelLayout)
+}
+
 func TestNoVideo(t *testing.T) {
 	require.NoError(t, os.MkdirAll(baseDir+"golden/meta", 0755))
 	require.NoError(t, os.MkdirAll(baseDir+"golden/export", 0755))
@@ -204,6 +832,27 @@ func TestAudioWithCover(t *testing.T) {
 	require.Greater(t, meta.Width, 0)
 	require.Greater(t, meta.Height, 0)
 
+	assert.True(t, meta.HasCover)
+	pic := av.AttachedPicture()
+	require.NotEmpty(t, pic)
+	// PNG cover art as is
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, pic[:4])
+	var covers []Stream
+	for _, stream := range meta.Streams {
+		if stream.AttachedPic {
+			covers = append(covers, stream)
+		}
+	}
+	require.NotEmpty(t, covers)
+	for _, cover := range []string{"front", "1", "99"} {
+		reader, err := os.Open(path)
+		require.NoError(t, err)
+		av, err := LoadAVContext(reader, stats.Size(), WithCover(cover))
+		require.NoError(t, err)
+		assert.Equal(t, meta, av.Metadata(), cover)
+		av.Close()
+	}
+
 	require.NoError(t, av.SelectFrame(1))
 	buf, err := av.Export(4)
 	require.NoError(t, err)
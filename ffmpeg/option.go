@@ -0,0 +1,139 @@
+package ffmpeg
+
+// Option AVContext option
+type Option func(av *AVContext)
+
+// WithSceneDetection with scene detection option,
+// which selects the best frame from inside the longest stable shot between scene cuts
+func WithSceneDetection(enabled bool) Option {
+	return func(av *AVContext) {
+		av.sceneDetection = enabled
+	}
+}
+
+// Strategy frame scoring strategy for automatic best frame selection
+type Strategy int
+
+// Strategy enum
+const (
+	// StrategyRMSE selects frame with histogram closest to the median histogram
+	StrategyRMSE Strategy = iota
+	// StrategySharpness selects frame with the highest Laplacian variance of luma, avoids motion blur
+	StrategySharpness
+	// StrategyEntropy selects frame with the highest luma entropy, avoids flat frames
+	StrategyEntropy
+	// StrategyColorfulness selects the most colorful frame, avoids washed out frames
+	StrategyColorfulness
+)
+
+// WithStrategy with frame scoring strategy option
+func WithStrategy(strategy Strategy) Option {
+	return func(av *AVContext) {
+		av.strategy = strategy
+	}
+}
+
+// WithFrameScorer with custom frame scorer option,
+// which takes precedence over frame scoring strategy
+func WithFrameScorer(scorer FrameScorer) Option {
+	return func(av *AVContext) {
+		av.scorer = scorer
+	}
+}
+
+// WithCropDetection with black borders detection option,
+// detected active picture rectangle is available as Metadata.Crop
+func WithCropDetection(enabled bool) Option {
+	return func(av *AVContext) {
+		av.cropDetection = enabled
+	}
+}
+
+// WithSampling with sampling mode option,
+// which selects the best frame among n keyframes sampled across the whole duration
+// instead of the consecutive frames from start
+func WithSampling(n int) Option {
+	return func(av *AVContext) {
+		av.samples = n
+	}
+}
+
+// WithFastDecode with fast decode option,
+// which decodes keyframes only and skips loop filtering,
+// trading precision and quality for decoding speed
+func WithFastDecode(enabled bool) Option {
+	return func(av *AVContext) {
+		av.fastDecode = enabled
+	}
+}
+
+// ToneMapping tone mapping algorithm of HDR to SDR conversion
+type ToneMapping int
+
+// ToneMapping enum
+const (
+	// ToneMapHable Hable filmic curve, preserves detail in both highlights and shadows
+	ToneMapHable ToneMapping = iota
+	// ToneMapMobius Mobius curve, preserves in-range colors and contrast, with more highlight clipping
+	ToneMapMobius
+	// ToneMapBT2390 ITU-R BT.2390 EETF, the reference roll-off curve in PQ domain
+	ToneMapBT2390
+	// ToneMapNone disables tone mapping
+	ToneMapNone
+)
+
+// WithToneMapping with tone mapping algorithm option for HDR video,
+// default ToneMapHable
+func WithToneMapping(toneMapping ToneMapping) Option {
+	return func(av *AVContext) {
+		av.toneMapping = toneMapping
+	}
+}
+
+// Deinterlace deinterlacing mode of frame conversion
+type Deinterlace int
+
+// Deinterlace enum
+const (
+	// DeinterlaceAuto deinterlaces frames flagged as interlaced
+	DeinterlaceAuto Deinterlace = iota
+	// DeinterlaceOn deinterlaces all frames
+	DeinterlaceOn
+	// DeinterlaceOff disables deinterlacing
+	DeinterlaceOff
+)
+
+// WithDeinterlace with deinterlacing mode option,
+// which blends the two fields of interlaced frames to remove combing.
+// Default DeinterlaceAuto
+func WithDeinterlace(deinterlace Deinterlace) Option {
+	return func(av *AVContext) {
+		av.deinterlace = deinterlace
+	}
+}
+
+// WithStreamIndex with index of the video stream to be processed,
+// instead of the best video stream of the media file
+func WithStreamIndex(index int) Option {
+	return func(av *AVContext) {
+		av.streamIndex = index
+	}
+}
+
+// WithStreamMatch with language or title of the video stream to be processed,
+// instead of the best video stream of the media file
+func WithStreamMatch(match string) Option {
+	return func(av *AVContext) {
+		av.streamMatch = match
+	}
+}
+
+// WithCover with embedded picture to be processed instead of the video stream,
+// such as cover art of audio, or Matroska and MP4 cover attachments of video.
+// By picture type front, back, booklet or artist, or 1-based position of the pictures.
+// Fallback to the first embedded picture, or the best video stream if none
+func WithCover(cover string) Option {
+	return func(av *AVContext) {
+		av.cover = cover
+	}
+}
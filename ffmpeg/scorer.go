@@ -0,0 +1,30 @@
+package ffmpeg
+
+import "time"
+
+// Frame candidate frame for FrameScorer
+type Frame struct {
+	// Index of frame among the candidate frames
+	Index int
+	// Duration presentation timestamp of frame, 0 if not available
+	Duration time.Duration
+	// Width and Height of frame
+	Width, Height int
+	// Buf RGBA pixels of frame
+	Buf []byte
+}
+
+// FrameScorer scores candidate frames for automatic best frame selection,
+// frame with the highest score is selected
+type FrameScorer interface {
+	// Score returns score of frame, or ok false to reject frame
+	Score(frame *Frame) (score float64, ok bool)
+}
+
+// FrameScorerFunc adapter to allow ordinary functions as FrameScorer
+type FrameScorerFunc func(frame *Frame) (score float64, ok bool)
+
+// Score implements FrameScorer interface
+func (f FrameScorerFunc) Score(frame *Frame) (float64, bool) {
+	return f(frame)
+}
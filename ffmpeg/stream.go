@@ -0,0 +1,248 @@
+package ffmpeg
+
+// #include "ffmpeg.h"
+import "C"
+import (
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// coverTypes ID3 picture types of the embedded pictures by cover name
+var coverTypes = map[string][]string{
+	"front":   {"Cover (front)"},
+	"back":    {"Cover (back)"},
+	"booklet": {"Leaflet page"},
+	"artist":  {"Artist/performer", "Lead artist/lead performer/soloist"},
+}
+
+// Stream stream of the media file
+type Stream struct {
+	Index          int      `json:"index"`
+	CodecType      string   `json:"codec_type"`
+	CodecName      string   `json:"codec_name,omitempty"`
+	Profile        string   `json:"profile,omitempty"`
+	Level          int      `json:"level,omitempty"`
+	BitRate        int64    `json:"bit_rate,omitempty"`
+	Width          int      `json:"width,omitempty"`
+	Height         int      `json:"height,omitempty"`
+	PixelFormat    string   `json:"pix_fmt,omitempty"`
+	BitDepth       int      `json:"bit_depth,omitempty"`
+	ColorRange     string   `json:"color_range,omitempty"`
+	ColorSpace     string   `json:"color_space,omitempty"`
+	ColorTransfer  string   `json:"color_transfer,omitempty"`
+	ColorPrimaries string   `json:"color_primaries,omitempty"`
+	SampleRate     int      `json:"sample_rate,omitempty"`
+	Channels       int      `json:"channels,omitempty"`
+	ChannelLayout  string   `json:"channel_layout,omitempty"`
+	Language       string   `json:"language,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Disposition    []string `json:"disposition,omitempty"`
+	Default        bool     `json:"default,omitempty"`
+	AttachedPic    bool     `json:"attached_pic,omitempty"`
+	PictureType    string   `json:"picture_type,omitempty"`
+	Selected       bool     `json:"selected,omitempty"`
+}
+
+// formatStreams all streams of the format context
+func formatStreams(av *AVContext) []*C.AVStream {
+	if av.closed || av.formatContext == nil || av.formatContext.nb_streams == 0 {
+		return nil
+	}
+	return unsafe.Slice(av.formatContext.streams, av.formatContext.nb_streams)
+}
+
+// streams all streams of the media file, with the selected video stream flagged
+func streams(av *AVContext) (list []Stream) {
+	for _, st := range formatStreams(av) {
+		par := st.codecpar
+		tags := dictEntries(st.metadata)
+		stream := Stream{
+			Index:       int(st.index),
+			CodecType:   C.GoString(C.av_get_media_type_string(par.codec_type)),
+			CodecName:   C.GoString(C.avcodec_get_name(par.codec_id)),
+			Profile:     C.GoString(C.avcodec_profile_name(par.codec_id, par.profile)),
+			BitRate:     int64(par.bit_rate),
+			BitDepth:    int(par.bits_per_raw_sample),
+			Language:    tags["language"],
+			Title:       tags["title"],
+			Disposition: disposition(st.disposition),
+			Default:     st.disposition&C.AV_DISPOSITION_DEFAULT != 0,
+			AttachedPic: isAttachedPic(st),
+			Selected:    av.stream != nil && st.index == av.stream.index,
+		}
+		if par.level > 0 {
+			stream.Level = int(par.level)
+		}
+		switch par.codec_type {
+		case C.AVMEDIA_TYPE_VIDEO:
+			videoStream(&stream, par)
+		case C.AVMEDIA_TYPE_AUDIO:
+			stream.SampleRate = int(par.sample_rate)
+			stream.Channels = int(par.ch_layout.nb_channels)
+			stream.ChannelLayout = channelLayout(par)
+		}
+		if stream.AttachedPic {
+			stream.PictureType = tags["comment"]
+		}
+		list = append(list, stream)
+	}
+	return
+}
+
+// videoStream dimensions, pixel format and color info of video stream
+func videoStream(stream *Stream, par *C.AVCodecParameters) {
+	stream.Width = int(par.width)
+	stream.Height = int(par.height)
+	if par.format >= 0 {
+		format := C.enum_AVPixelFormat(par.format)
+		stream.PixelFormat = C.GoString(C.av_get_pix_fmt_name(format))
+		if desc := C.av_pix_fmt_desc_get(format); desc != nil && stream.BitDepth == 0 {
+			stream.BitDepth = int(desc.comp[0].depth)
+		}
+	}
+	if par.color_range != C.AVCOL_RANGE_UNSPECIFIED {
+		stream.ColorRange = C.GoString(C.av_color_range_name(par.color_range))
+	}
+	if par.color_space != C.AVCOL_SPC_UNSPECIFIED {
+		stream.ColorSpace = C.GoString(C.av_color_space_name(par.color_space))
+	}
+	if par.color_trc != C.AVCOL_TRC_UNSPECIFIED {
+		stream.ColorTransfer = C.GoString(C.av_color_transfer_name(par.color_trc))
+	}
+	if par.color_primaries != C.AVCOL_PRI_UNSPECIFIED {
+		stream.ColorPrimaries = C.GoString(C.av_color_primaries_name(par.color_primaries))
+	}
+}
+
+// audio codec, sample rate and channels of the best audio stream
+func audio(av *AVContext) *Audio {
+	st := audioStream(av)
+	if st == nil {
+		return nil
+	}
+	par := st.codecpar
+	return &Audio{
+		Codec:         C.GoString(C.avcodec_get_name(par.codec_id)),
+		SampleRate:    int(par.sample_rate),
+		Channels:      int(par.ch_layout.nb_channels),
+		ChannelLayout: channelLayout(par),
+		BitRate:       int64(par.bit_rate),
+	}
+}
+
+// audioStream the best audio stream, nil if none
+func audioStream(av *AVContext) *C.AVStream {
+	if !av.hasAudio || av.closed || av.formatContext == nil {
+		return nil
+	}
+	i := C.av_find_best_stream(av.formatContext, C.AVMEDIA_TYPE_AUDIO, -1, -1, nil, 0)
+	if i < 0 {
+		return nil
+	}
+	return formatStreams(av)[i]
+}
+
+// channelLayout name of the channel layout, such as stereo or 5.1
+func channelLayout(par *C.AVCodecParameters) string {
+	if par.ch_layout.nb_channels <= 0 {
+		return ""
+	}
+	var buf [64]C.char
+	if C.av_channel_layout_describe(&par.ch_layout, &buf[0], C.size_t(len(buf))) < 0 {
+		return ""
+	}
+	return C.GoString(&buf[0])
+}
+
+// disposition names of the disposition flags
+func disposition(flags C.int) (names []string) {
+	for flag := C.int(1); flag > 0 && flag <= flags; flag <<= 1 {
+		if flags&flag != 0 {
+			if name := C.av_disposition_to_string(flag); name != nil {
+				names = append(names, C.GoString(name))
+			}
+		}
+	}
+	return
+}
+
+// wantedStream index of the video stream requested by index, or by language or title match.
+// Returns -1 for the best video stream if not requested
+func wantedStream(av *AVContext) (int, error) {
+	if av.cover != "" && av.streamIndex < 0 && av.streamMatch == "" {
+		return coverStream(av), nil
+	}
+	if av.streamMatch == "" {
+		return av.streamIndex, nil
+	}
+	for _, st := range formatStreams(av) {
+		if st.codecpar.codec_type != C.AVMEDIA_TYPE_VIDEO {
+			continue
+		}
+		tags := dictEntries(st.metadata)
+		if strings.EqualFold(tags["language"], av.streamMatch) ||
+			strings.EqualFold(tags["title"], av.streamMatch) {
+			return int(st.index), nil
+		}
+	}
+	return -1, ErrStreamNotFound
+}
+
+// coverStream index of the embedded picture by cover name or 1-based position,
+// fallback to the first embedded picture, or -1 for the best video stream if none
+func coverStream(av *AVContext) int {
+	n, _ := strconv.Atoi(av.cover)
+	name := strings.ToLower(av.cover)
+	types := coverTypes[name]
+	first, i := -1, 0
+	for _, st := range formatStreams(av) {
+		if !isAttachedPic(st) {
+			continue
+		}
+		if i++; i == 1 {
+			first = int(st.index)
+		}
+		if i == n {
+			return int(st.index)
+		}
+		tags := dictEntries(st.metadata)
+		for _, typ := range types {
+			if strings.EqualFold(tags["comment"], typ) {
+				return int(st.index)
+			}
+		}
+		// Matroska cover art attachment named cover.jpg or cover.png
+		if name == "front" && strings.HasPrefix(strings.ToLower(tags["filename"]), "cover.") {
+			return int(st.index)
+		}
+	}
+	return first
+}
+
+// hasCover whether the media file has embedded picture
+func hasCover(av *AVContext) bool {
+	for _, st := range formatStreams(av) {
+		if isAttachedPic(st) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAttachedPic(st *C.AVStream) bool {
+	return st.codecpar.codec_type == C.AVMEDIA_TYPE_VIDEO && st.disposition&C.AV_DISPOSITION_ATTACHED_PIC != 0
+}
+
+// dictEntries entries of AVDictionary, keys in lower case
+func dictEntries(dict *C.AVDictionary) map[string]string {
+	entries := map[string]string{}
+	var tag *C.AVDictionaryEntry
+	for {
+		if tag = C.av_dict_iterate(dict, tag); tag == nil {
+			break
+		}
+		entries[strings.ToLower(C.GoString(tag.key))] = C.GoString(tag.value)
+	}
+	return entries
+}
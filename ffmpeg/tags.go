@@ -0,0 +1,69 @@
+package ffmpeg
+
+import (
+	"sort"
+	"strings"
+)
+
+// formatTags tags of the media file, keys in lower case.
+// Fallback to the tags of the best audio stream, such as Ogg Vorbis comments
+func formatTags(av *AVContext) map[string]string {
+	if av.closed || av.formatContext == nil {
+		return nil
+	}
+	tags := dictEntries(av.formatContext.metadata)
+	if len(tags) == 0 {
+		if st := audioStream(av); st != nil {
+			tags = dictEntries(st.metadata)
+		}
+	}
+	return tags
+}
+
+// mediaTags populates the well-known tags of metadata,
+// with the remaining tags left in the generic tags map
+func mediaTags(av *AVContext, meta *Metadata) {
+	tags := formatTags(av)
+	if meta.Title == "" {
+		meta.Title = tags["title"]
+	}
+	if meta.Artist == "" {
+		meta.Artist = tags["artist"]
+	}
+	delete(tags, "title")
+	delete(tags, "artist")
+	meta.Album = popTag(tags, "album")
+	meta.AlbumArtist = popTag(tags, "album_artist")
+	meta.Genre = popTag(tags, "genre")
+	meta.Track = popTag(tags, "track")
+	meta.Disc = popTag(tags, "disc")
+	meta.Date = popTag(tags, "date")
+	meta.Composer = popTag(tags, "composer")
+	meta.Lyrics = popTag(tags, lyricsKey(tags))
+	captureTags(av, tags, meta)
+	if len(tags) > 0 {
+		meta.Tags = tags
+	}
+}
+
+func popTag(tags map[string]string, key string) string {
+	value := tags[key]
+	delete(tags, key)
+	return value
+}
+
+// lyricsKey key of the lyrics tag, such as lyrics of MP4,
+// or lyrics-eng of ID3 unsynchronised lyrics with language
+func lyricsKey(tags map[string]string) string {
+	var keys []string
+	for key := range tags {
+		if key == "lyrics" || strings.HasPrefix(key, "lyrics-") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
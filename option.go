@@ -1,6 +1,10 @@
 package imagorvideo
 
-import "go.uber.org/zap"
+import (
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+	"go.uber.org/zap"
+)
 
 // Option imagorvideo option
 type Option func(p *Processor)
@@ -27,3 +31,27 @@ func WithFallbackImage(image string) Option {
 		p.FallbackImage = image
 	}
 }
+
+// WithFrameScorer with custom frame scorer option for automatic best frame selection.
+// Falls back to fallback image if all candidate frames are rejected
+func WithFrameScorer(scorer ffmpeg.FrameScorer) Option {
+	return func(p *Processor) {
+		p.FrameScorer = scorer
+	}
+}
+
+// WithFastDecode with fast decode option by default,
+// which decodes keyframes only and skips loop filtering
+func WithFastDecode(enabled bool) Option {
+	return func(p *Processor) {
+		p.FastDecode = enabled
+	}
+}
+
+// WithSigner with imagor URL signer option,
+// for signing the sprite sheet URL referenced by vtt() of signed request
+func WithSigner(signer imagorpath.Signer) Option {
+	return func(p *Processor) {
+		p.Signer = signer
+	}
+}
@@ -0,0 +1,86 @@
+package imagorvideo
+
+import "github.com/cshum/imagor/imagorpath"
+
+// orientFlips splits the EXIF orientation into rotation,
+// and the horizontal or vertical flip that follows the rotation
+func orientFlips(orientation int) (rotation int, hflip, vflip bool) {
+	switch orientation {
+	case 2:
+		return 1, true, false
+	case 4:
+		return 1, false, true
+	case 5:
+		return 6, true, false
+	case 7:
+		return 6, false, true
+	}
+	return orientation, false, false
+}
+
+// orientFilters returns imagor filters that apply the rotation of EXIF orientation.
+// Flips of mirrored orientations are applied by orientParams
+func orientFilters(orientation int) (filters imagorpath.Filters) {
+	rotation, _, _ := orientFlips(orientation)
+	switch rotation {
+	case 3:
+		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "180"})
+	case 6:
+		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "270"})
+	case 8:
+		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "90"})
+	}
+	return
+}
+
+// orientParams applies the flips of mirrored EXIF orientation to imagor params,
+// which imagor performs after orient filter, crop and resize
+func orientParams(params imagorpath.Params, orientation int) (imagorpath.Params, bool) {
+	_, hflip, vflip := orientFlips(orientation)
+	params.HFlip = params.HFlip != hflip
+	params.VFlip = params.VFlip != vflip
+	return params, hflip || vflip
+}
+
+// orientBuffer applies the EXIF orientation to RGB or RGBA buffer,
+// returns the oriented buffer and its dimensions
+func orientBuffer(buf []byte, width, height, bands, orientation int) ([]byte, int, int) {
+	if orientation < 2 || orientation > 8 {
+		return buf, width, height
+	}
+	w, h := width, height
+	if orientation >= 5 {
+		w, h = height, width
+	}
+	out := make([]byte, len(buf))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dx, dy := orientPoint(x, y, width, height, orientation)
+			src := (y*width + x) * bands
+			copy(out[(dy*w+dx)*bands:], buf[src:src+bands])
+		}
+	}
+	return out, w, h
+}
+
+// orientPoint maps pixel coordinates of image of the specified dimensions
+// to the coordinates after applying the EXIF orientation
+func orientPoint(x, y, width, height, orientation int) (int, int) {
+	switch orientation {
+	case 2:
+		return width - 1 - x, y
+	case 3:
+		return width - 1 - x, height - 1 - y
+	case 4:
+		return x, height - 1 - y
+	case 5:
+		return y, x
+	case 6:
+		return height - 1 - y, x
+	case 7:
+		return height - 1 - y, width - 1 - x
+	case 8:
+		return y, width - 1 - x
+	}
+	return x, y
+}
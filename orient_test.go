@@ -0,0 +1,81 @@
+package imagorvideo
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+// orientedMeta metadata of video of square pixel frames with EXIF orientation
+func orientedMeta(width, height, orientation int) *ffmpeg.Metadata {
+	meta := &ffmpeg.Metadata{
+		Orientation: orientation, Width: width, Height: height, DisplayWidth: width, DisplayHeight: height,
+	}
+	switch orientation {
+	case 3, 4:
+		meta.Rotation = 180
+	case 5, 6:
+		meta.Rotation = 90
+	case 7, 8:
+		meta.Rotation = 270
+	}
+	if meta.Rotation == 90 || meta.Rotation == 270 {
+		meta.DisplayWidth, meta.DisplayHeight = height, width
+	}
+	return meta
+}
+
+func TestOrientBuffer(t *testing.T) {
+	// 3x2 single band image
+	// 1 2 3
+	// 4 5 6
+	buf := []byte{1, 2, 3, 4, 5, 6}
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		expected    []byte
+	}{
+		{orientation: 1, width: 3, height: 2, expected: []byte{1, 2, 3, 4, 5, 6}},
+		{orientation: 2, width: 3, height: 2, expected: []byte{3, 2, 1, 6, 5, 4}},
+		{orientation: 3, width: 3, height: 2, expected: []byte{6, 5, 4, 3, 2, 1}},
+		{orientation: 4, width: 3, height: 2, expected: []byte{4, 5, 6, 1, 2, 3}},
+		{orientation: 5, width: 2, height: 3, expected: []byte{1, 4, 2, 5, 3, 6}},
+		{orientation: 6, width: 2, height: 3, expected: []byte{4, 1, 5, 2, 6, 3}},
+		{orientation: 7, width: 2, height: 3, expected: []byte{6, 3, 5, 2, 4, 1}},
+		{orientation: 8, width: 2, height: 3, expected: []byte{3, 6, 2, 5, 1, 4}},
+	}
+	for _, tt := range tests {
+		out, w, h := orientBuffer(buf, 3, 2, 1, tt.orientation)
+		assert.Equal(t, tt.width, w, "orientation %d", tt.orientation)
+		assert.Equal(t, tt.height, h, "orientation %d", tt.orientation)
+		assert.Equal(t, tt.expected, out, "orientation %d", tt.orientation)
+	}
+}
+
+func TestOrientFlips(t *testing.T) {
+	// rotation followed by flips should match the mirrored orientation
+	buf := []byte{1, 2, 3, 4, 5, 6}
+	for orientation := 1; orientation <= 8; orientation++ {
+		rotation, hflip, vflip := orientFlips(orientation)
+		out, w, h := orientBuffer(buf, 3, 2, 1, rotation)
+		if hflip {
+			out, w, h = orientBuffer(out, w, h, 1, 2)
+		}
+		if vflip {
+			out, w, h = orientBuffer(out, w, h, 1, 4)
+		}
+		expected, ew, eh := orientBuffer(buf, 3, 2, 1, orientation)
+		assert.Equal(t, ew, w, "orientation %d", orientation)
+		assert.Equal(t, eh, h, "orientation %d", orientation)
+		assert.Equal(t, expected, out, "orientation %d", orientation)
+
+		params, ok := orientParams(imagorpath.Params{HFlip: true}, orientation)
+		assert.Equal(t, hflip || vflip, ok, "orientation %d", orientation)
+		assert.Equal(t, !hflip, params.HFlip, "orientation %d", orientation)
+		assert.Equal(t, vflip, params.VFlip, "orientation %d", orientation)
+		assert.Len(t, orientFilters(orientation), len(orientFilters(rotation)), "orientation %d", orientation)
+	}
+}
@@ -0,0 +1,82 @@
+package imagorvideo
+
+import (
+	"math"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/vipsgen/vips"
+)
+
+const (
+	maxPreviewFrames = 50
+	// maxPreviewBytes max byte size of the stacked preview frames
+	maxPreviewBytes = 64 << 20
+	previewDelay    = 500 * time.Millisecond
+)
+
+// previewSize dimensions of each exported frame of n frames preview,
+// downscaled further if the stacked frames would exceed maxPreviewBytes
+func previewSize(width, height, n, bands int) (int, int) {
+	size := float64(n * width * height * bands)
+	if width <= 0 || height <= 0 || size <= maxPreviewBytes {
+		return width, height
+	}
+	scale := math.Sqrt(maxPreviewBytes / size)
+	width = max(int(float64(width)*scale), 1)
+	height = max(int(float64(height)*scale), 1)
+	return width, height
+}
+
+// sampleDurations returns n sample points starting from start,
+// spaced by interval if specified, otherwise spread evenly until end.
+// Sample points exceeding end are dropped if end is known
+func sampleDurations(n int, start, end, interval time.Duration) []time.Duration {
+	if n < 1 {
+		return nil
+	}
+	if interval <= 0 {
+		if end > start {
+			interval = (end - start) / time.Duration(n)
+		} else {
+			interval = time.Second
+		}
+	}
+	durations := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		ts := start + interval*time.Duration(i)
+		if end > 0 && ts >= end && i > 0 {
+			break
+		}
+		durations = append(durations, ts)
+	}
+	return durations
+}
+
+// newPreviewBlob encodes n frames stacked in buf as animated WebP blob,
+// so that imagor processor can save them as animated WebP or GIF
+func newPreviewBlob(buf []byte, n, width, height, bands int) (*imagor.Blob, error) {
+	img, err := vips.NewImageFromMemory(buf, width, height*n, bands)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	if err = img.SetPageHeight(height); err != nil {
+		return nil, err
+	}
+	delay := make([]int, n)
+	for i := range delay {
+		delay[i] = int(previewDelay / time.Millisecond)
+	}
+	if err = img.SetArrayInt("delay", delay); err != nil {
+		return nil, err
+	}
+	img.SetInt("loop", 0)
+	opts := vips.DefaultWebpsaveBufferOptions()
+	opts.Lossless = true
+	opts.Effort = 0
+	if buf, err = img.WebpsaveBuffer(opts); err != nil {
+		return nil, err
+	}
+	return imagor.NewBlobFromBytes(buf), nil
+}
@@ -3,6 +3,8 @@ package imagorvideo
 import (
 	"context"
 	"io"
+	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -19,6 +21,9 @@ type Processor struct {
 	Logger        *zap.Logger
 	Debug         bool
 	FallbackImage string
+	FrameScorer   ffmpeg.FrameScorer
+	FastDecode    bool
+	Signer        imagorpath.Signer
 }
 
 // NewProcessor creates Processor
@@ -70,6 +75,10 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 		if _, ok := err.(imagor.ErrForward); ok {
 			return
 		}
+		if e, ok := err.(imagor.Error); ok && e.Code == http.StatusBadRequest {
+			// invalid request, not to be covered by fallback image
+			return
+		}
 		err = imagor.NewError(err.Error(), 406)
 		// fallback image on error
 		out = imagor.NewBlobFromBytes(transPixel)
@@ -111,13 +120,30 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 			return
 		}
 	}
-	av, err := ffmpeg.LoadAVContext(rs, size)
+	av, err := ffmpeg.LoadAVContext(rs, size, p.avOptions(params)...)
 	if err != nil {
 		return
 	}
 	defer av.Close()
 	meta := av.Metadata()
+	if meta.FastDecode {
+		defer func() {
+			if out != nil {
+				if out.Header == nil {
+					out.Header = make(http.Header)
+				}
+				out.Header.Set("Imagor-Video-Fast-Decode", "1")
+			}
+		}()
+	}
+	autocrop := imagorpath.HasFilter(params, "autocrop")
 	if params.Meta {
+		if autocrop && meta.HasVideo {
+			if err = av.ProcessFrames(-1); err != nil {
+				return
+			}
+			meta = av.Metadata()
+		}
 		out = imagor.NewBlobFromJsonMarshal(Metadata{
 			Format:      strings.TrimPrefix(mime.Extension(), "."),
 			ContentType: mime.String(),
@@ -125,7 +151,36 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 		})
 		return
 	}
-	bands := 3
+	if passthrough(params) {
+		if pic := av.AttachedPicture(); pic != nil {
+			// cover art as is without decoding and re-encoding
+			out = imagor.NewBlobFromBytes(pic)
+			return
+		}
+	}
+	exportWidth, exportHeight, downscale := exportSize(params, meta)
+	if downscale {
+		if err = av.SetExportSize(exportWidth, exportHeight); err != nil {
+			return
+		}
+	} else {
+		exportWidth, exportHeight = meta.FrameSize()
+	}
+	var (
+		bands           = 3
+		start           time.Duration
+		end             = metaDuration(meta)
+		previewN        int
+		previewInterval time.Duration
+		sprite          spriteGrid
+		vtt             bool
+		vttURL          string
+		bif             bool
+		bifInterval     time.Duration
+		cropped         bool
+		flipped         bool
+		highDepth       bool
+	)
 	for _, filter := range params.Filters {
 		switch filter.Name {
 		case "format":
@@ -136,8 +191,17 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 					bands = 4
 				}
 			}
+		case "bitdepth":
+			highDepth = strings.TrimSpace(filter.Args) == "16"
 		case "frame":
-			if ts, e := time.ParseDuration(filter.Args); e == nil {
+			if n, ok := chapterArg(filter.Args); ok {
+				if err = seekChapter(av, n); err != nil {
+					return
+				}
+				if err = av.ProcessFrames(-1); err != nil {
+					return
+				}
+			} else if ts, e := time.ParseDuration(filter.Args); e == nil {
 				if err = av.SelectDuration(ts); err != nil {
 					return
 				}
@@ -153,11 +217,20 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 				}
 			}
 		case "seek":
-			if ts, e := time.ParseDuration(filter.Args); e == nil {
+			if n, ok := chapterArg(filter.Args); ok {
+				if err = seekChapter(av, n); err != nil {
+					return
+				}
+				// sampling within the chapter
+				start = time.Duration(meta.Chapters[n-1].Start) * time.Millisecond
+				end = time.Duration(meta.Chapters[n-1].End) * time.Millisecond
+			} else if ts, e := time.ParseDuration(filter.Args); e == nil {
+				start = ts
 				if err = av.SeekDuration(ts); err != nil {
 					return
 				}
 			} else if f, e := strconv.ParseFloat(filter.Args, 64); e == nil {
+				start = positionToDuration(meta, f)
 				if err = av.SeekPosition(f); err != nil {
 					return
 				}
@@ -167,27 +240,106 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 			if err = av.ProcessFrames(n); err != nil {
 				return
 			}
+		case "preview":
+			args := strings.Split(filter.Args, ",")
+			previewN, _ = strconv.Atoi(strings.TrimSpace(args[0]))
+			if len(args) > 1 {
+				previewInterval, _ = time.ParseDuration(strings.TrimSpace(args[1]))
+			}
+		case "sprite":
+			if g, ok := parseSpriteGrid(filter.Args); ok {
+				sprite = g
+			}
+		case "vtt":
+			vtt = true
+			vttURL = filter.Args
+		case "bif":
+			bif = true
+			bifInterval, _ = time.ParseDuration(filter.Args)
 		}
 	}
 
-	switch meta.Orientation {
-	case 3:
-		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "180"})
-	case 6:
-		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "270"})
-	case 8:
-		filters = append(filters, imagorpath.Filter{Name: "orient", Args: "90"})
-	}
-	buf, err := av.Export(bands)
-	if err != nil || len(buf) == 0 {
-		if err == nil {
-			err = imagor.ErrUnsupportedFormat
+	switch {
+	case bif:
+		out, err = newBIFBlob(av, meta, params, start, end, bifInterval)
+		return
+	case sprite.Cols > 0 && vtt:
+		if vttURL == "" {
+			if vttURL = spritePath(params, p.Signer); vttURL == "" {
+				err = errVTTWithoutURL
+				return
+			}
 		}
+		durations := sprite.Durations(start, end)
+		out = newSpriteVTTBlob(vttURL, params, meta, sprite, durations, end)
+		return
+	case vtt:
+		err = errVTTWithoutSprite
 		return
+	case sprite.Cols > 0:
+		// exported in tile size, with orientation applied to each tile
+		durations := sprite.Durations(start, end)
+		if out, err = newSpriteBlob(av, meta, params, sprite, durations, bands); err != nil {
+			return
+		}
+	case previewN > 0:
+		if previewN > maxPreviewFrames {
+			previewN = maxPreviewFrames
+		}
+		durations := sampleDurations(previewN, start, end, previewInterval)
+		if w, h := previewSize(exportWidth, exportHeight, len(durations), bands); w != exportWidth || h != exportHeight {
+			exportWidth, exportHeight = w, h
+			if err = av.SetExportSize(exportWidth, exportHeight); err != nil {
+				return
+			}
+		}
+		width, height := exportWidth, exportHeight
+		_, hflip, vflip := orientFlips(meta.Orientation)
+		// frames exported in the downscaled size, stacked into a single buffer
+		frames := make([]byte, 0, len(durations)*width*height*bands)
+		if err = av.ExportFramesFunc(durations, bands, func(_ int, buf []byte) error {
+			if hflip || vflip {
+				// mirrored orientation applied to each frame,
+				// as imagor flips animated image as a whole
+				buf, width, height = orientBuffer(buf, exportWidth, exportHeight, bands, meta.Orientation)
+			}
+			frames = append(frames, buf...)
+			return nil
+		}); err != nil {
+			return
+		}
+		if !hflip && !vflip {
+			filters = append(filters, orientFilters(meta.Orientation)...)
+		}
+		if out, err = newPreviewBlob(frames, len(durations), width, height, bands); err != nil {
+			return
+		}
+	default:
+		if highDepth {
+			av.SetExportDepth(16)
+		}
+		buf, e := av.Export(bands)
+		if e != nil || len(buf) == 0 {
+			if err = e; err == nil {
+				err = imagor.ErrUnsupportedFormat
+			}
+			return
+		}
+		if highDepth {
+			if out, err = newHighDepthBlob(buf, exportWidth, exportHeight, bands); err != nil {
+				return
+			}
+		} else {
+			out = imagor.NewBlobFromMemory(buf, exportWidth, exportHeight, bands)
+		}
+		filters = append(filters, orientFilters(meta.Orientation)...)
+		params, flipped = orientParams(params, meta.Orientation)
+		if autocrop && !imagorpath.HasCrop(params) {
+			params, cropped = cropParams(params, av.Metadata())
+		}
 	}
-	out = imagor.NewBlobFromMemory(buf, meta.Width, meta.Height, bands)
 
-	if len(filters) > 0 {
+	if len(filters) > 0 || cropped || flipped {
 		params.Filters = append(params.Filters, filters...)
 		params.Path = imagorpath.GeneratePath(params)
 	}
@@ -195,6 +347,158 @@ func (p *Processor) Process(ctx context.Context, in *imagor.Blob, params imagorp
 	return
 }
 
+// avOptions ffmpeg AVContext options from params
+func (p *Processor) avOptions(params imagorpath.Params) (options []ffmpeg.Option) {
+	if p.FrameScorer != nil {
+		options = append(options, ffmpeg.WithFrameScorer(p.FrameScorer))
+	}
+	fastDecode := p.FastDecode
+	for _, filter := range params.Filters {
+		switch filter.Name {
+		case "fast":
+			fastDecode = true
+			if b, e := strconv.ParseBool(filter.Args); e == nil {
+				fastDecode = b
+			}
+		case "scene":
+			options = append(options, ffmpeg.WithSceneDetection(true))
+		case "autocrop":
+			options = append(options, ffmpeg.WithCropDetection(true))
+		case "sample":
+			n, _ := strconv.Atoi(filter.Args)
+			if n < 1 {
+				n = defaultSamples
+			}
+			options = append(options, ffmpeg.WithSampling(n))
+		case "strategy":
+			if strategy, ok := strategies[strings.ToLower(filter.Args)]; ok {
+				options = append(options, ffmpeg.WithStrategy(strategy))
+			}
+		case "tonemap":
+			if toneMapping, ok := toneMappings[strings.ToLower(filter.Args)]; ok {
+				options = append(options, ffmpeg.WithToneMapping(toneMapping))
+			}
+		case "cover":
+			cover := filter.Args
+			if cover == "" {
+				cover = "front"
+			}
+			options = append(options, ffmpeg.WithCover(cover))
+		case "stream":
+			if n, e := strconv.Atoi(filter.Args); e == nil && n >= 0 {
+				options = append(options, ffmpeg.WithStreamIndex(n))
+			} else if filter.Args != "" {
+				options = append(options, ffmpeg.WithStreamMatch(filter.Args))
+			}
+		case "deinterlace":
+			if deinterlace, ok := deinterlaceModes[strings.ToLower(filter.Args)]; ok {
+				options = append(options, ffmpeg.WithDeinterlace(deinterlace))
+			}
+		}
+	}
+	if fastDecode {
+		options = append(options, ffmpeg.WithFastDecode(true))
+	}
+	return
+}
+
+// passthrough whether the request has no resize, crop, flip nor filters other than cover,
+// so that the attached picture can be forwarded as is
+func passthrough(params imagorpath.Params) bool {
+	if params.Width != 0 || params.Height != 0 || imagorpath.HasCrop(params) ||
+		params.Trim || params.HFlip || params.VFlip || params.Smart {
+		return false
+	}
+	for _, filter := range params.Filters {
+		if filter.Name != "cover" {
+			return false
+		}
+	}
+	return true
+}
+
+// exportSize dimensions of the frame export downscaled to the resize of the request,
+// so that the buffer handed to vips is only as big as needed.
+// Not applicable when the request depends on the source dimensions
+func exportSize(params imagorpath.Params, meta *ffmpeg.Metadata) (width, height int, ok bool) {
+	frameWidth, frameHeight := meta.FrameSize()
+	if frameWidth <= 0 || frameHeight <= 0 || imagorpath.HasCrop(params) ||
+		params.Trim || params.Stretch || params.AdaptiveFitIn || params.FullFitIn {
+		return
+	}
+	for _, filter := range params.Filters {
+		switch filter.Name {
+		case "sprite", "bif", "autocrop", "focal":
+			return
+		}
+	}
+	w, h := frameWidth, frameHeight
+	if meta.Orientation >= 5 {
+		w, h = h, w
+	}
+	scale, _, _ := resizeScale(params, w, h)
+	if scale <= 0 || scale >= 1 {
+		return
+	}
+	width = max(int(math.Round(float64(frameWidth)*scale)), 1)
+	height = max(int(math.Round(float64(frameHeight)*scale)), 1)
+	return width, height, true
+}
+
+func metaDuration(meta *ffmpeg.Metadata) time.Duration {
+	return time.Duration(meta.Duration) * time.Millisecond
+}
+
+func positionToDuration(meta *ffmpeg.Metadata, f float64) time.Duration {
+	return time.Duration(float64(metaDuration(meta)) * math.Max(math.Min(f, 1), 0))
+}
+
+// chapterArg 1-based chapter number of the chapter:N filter argument
+func chapterArg(arg string) (int, bool) {
+	if v, ok := strings.CutPrefix(strings.TrimSpace(arg), "chapter:"); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// seekChapter seeks to the start of the 1-based chapter,
+// of which chapter not listed in metadata is an invalid request
+func seekChapter(av *ffmpeg.AVContext, n int) error {
+	if err := av.SeekChapter(n); err != nil {
+		if err == ffmpeg.ErrChapterNotFound {
+			return errChapterNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+const defaultSamples = 20
+
+var errChapterNotFound = imagor.NewError("chapter not found", http.StatusBadRequest)
+
+var strategies = map[string]ffmpeg.Strategy{
+	"rmse":         ffmpeg.StrategyRMSE,
+	"sharpness":    ffmpeg.StrategySharpness,
+	"entropy":      ffmpeg.StrategyEntropy,
+	"colorfulness": ffmpeg.StrategyColorfulness,
+}
+
+var toneMappings = map[string]ffmpeg.ToneMapping{
+	"hable":  ffmpeg.ToneMapHable,
+	"mobius": ffmpeg.ToneMapMobius,
+	"bt2390": ffmpeg.ToneMapBT2390,
+	"none":   ffmpeg.ToneMapNone,
+}
+
+var deinterlaceModes = map[string]ffmpeg.Deinterlace{
+	"auto": ffmpeg.DeinterlaceAuto,
+	"on":   ffmpeg.DeinterlaceOn,
+	"off":  ffmpeg.DeinterlaceOff,
+}
+
 // Metadata imagorvideo metadata
 type Metadata struct {
 	Format      string `json:"format"`
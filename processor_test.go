@@ -3,6 +3,7 @@ package imagorvideo
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,6 +21,7 @@ import (
 	"github.com/cshum/imagor/imagorpath"
 	"github.com/cshum/imagor/processor/vipsprocessor"
 	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/cshum/imagorvideo/ffmpeg"
 	"github.com/cshum/vipsgen/vips"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,6 +39,21 @@ type test struct {
 	name       string
 	path       string
 	expectCode int
+	// decoded response asserted in addition to the golden,
+	// of the dimensions of each page, number of pages, bands and pixels
+	width, height int
+	pages         int
+	bands         int
+	highDepth     bool
+	pixels        []pixel
+	// number of frames of BIF archive, of which the first frame is decoded
+	bifFrames int
+}
+
+// pixel expected color at x, y of the decoded response
+type pixel struct {
+	x, y  int
+	color [3]float64
 }
 
 func TestProcessor(t *testing.T) {
@@ -47,31 +64,189 @@ func TestProcessor(t *testing.T) {
 	})
 	t.Run("source save uses single open with read seeker", runVideoProcessorSourceSaveUsesSingleOpenWithReadSeeker)
 	doGoldenTests(t, filepath.Join(testDataDir, "golden/result"), []test{
-		{name: "mkv", path: "fit-in/100x100/everybody-betray-me.mkv"},
-		{name: "mkv specific frame", path: "fit-in/100x100/filters:frame(3)/everybody-betray-me.mkv"},
-		{name: "mkv specific max_frames", path: "fit-in/100x100/filters:max_frames(6)/everybody-betray-me.mkv"},
-		{name: "mkv specific frame exceeded", path: "fit-in/100x100/filters:frame(99999)/everybody-betray-me.mkv"},
+		{name: "mkv", path: "fit-in/100x100/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv specific frame", path: "fit-in/100x100/filters:frame(3)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv specific max_frames", path: "fit-in/100x100/filters:max_frames(6)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv specific frame exceeded", path: "fit-in/100x100/filters:frame(99999)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv scene", path: "fit-in/100x100/filters:scene()/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mp4 seek scene", path: "200x100/filters:seek(0.3):scene()/macabre.mp4",
+			width: 200, height: 100},
+		{name: "mkv strategy sharpness", path: "fit-in/100x100/filters:strategy(sharpness)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv strategy entropy", path: "fit-in/100x100/filters:strategy(entropy)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mp4 strategy colorfulness", path: "200x100/filters:strategy(colorfulness)/macabre.mp4",
+			width: 200, height: 100},
+		{name: "mp4 scene strategy sharpness", path: "200x100/filters:scene():strategy(sharpness)/macabre.mp4",
+			width: 200, height: 100},
+		{name: "strategy colorfulness", path: "filters:strategy(colorfulness)/strategy.mkv",
+			width: 64, height: 48, pixels: []pixel{{x: 16, y: 24, color: [3]float64{255, 0, 0}}, {x: 48, y: 24, color: [3]float64{0, 0, 255}}}},
+		{name: "mkv autocrop", path: "fit-in/100x100/filters:autocrop()/everybody-betray-me.mkv",
+			width: 100, height: 56},
+		{name: "mp4 autocrop orient 90", path: "fit-in/100x100/filters:autocrop()/schizo_90.mp4",
+			width: 100, height: 75},
+		{name: "mkv meta autocrop", path: "meta/filters:autocrop()/everybody-betray-me.mkv"},
+		{name: "letterbox meta autocrop", path: "meta/filters:autocrop()/letterbox.mkv"},
+		{name: "letterbox autocrop", path: "filters:autocrop()/letterbox.mkv",
+			width: 64, height: 32},
+		{name: "mkv sample", path: "fit-in/100x100/filters:sample()/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mp4 sample n", path: "200x100/filters:sample(8)/macabre.mp4",
+			width: 200, height: 100},
+		{name: "mp4 seek sample", path: "200x100/filters:seek(0.5):sample(5)/macabre.mp4",
+			width: 200, height: 100},
+		{name: "mkv fast", path: "fit-in/100x100/filters:fast()/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mp4 fast seek", path: "200x100/filters:seek(0.5):fast()/macabre.mp4",
+			width: 200, height: 100},
+		{name: "mkv meta fast", path: "meta/filters:fast()/everybody-betray-me.mkv"},
+		{name: "mkv tonemap sdr", path: "fit-in/100x100/filters:tonemap(mobius)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "pq tonemap", path: "filters:tonemap(bt2390)/hdr-pq.mkv",
+			width: 48, height: 16, pixels: []pixel{
+				{x: 8, y: 8, color: [3]float64{0, 0, 0}},
+				{x: 24, y: 8, color: [3]float64{225, 225, 225}},
+				{x: 40, y: 8, color: [3]float64{255, 255, 255}},
+			}},
+		{name: "hlg tonemap", path: "filters:tonemap(hable)/hdr-hlg.mkv",
+			width: 48, height: 16, pixels: []pixel{
+				{x: 8, y: 8, color: [3]float64{0, 0, 0}},
+				{x: 24, y: 8, color: [3]float64{157, 157, 157}},
+				{x: 40, y: 8, color: [3]float64{254, 254, 254}},
+			}},
+		{name: "pq meta", path: "meta/hdr-pq.mkv"},
+		{name: "mkv stream index", path: "fit-in/100x100/filters:stream(0)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv stream audio", path: "fit-in/100x100/filters:stream(1)/everybody-betray-me.mkv", expectCode: 406},
+		{name: "mkv meta stream", path: "meta/filters:stream(0)/everybody-betray-me.mkv"},
+		{name: "streams default", path: "streams.mkv",
+			width: 64, height: 48, pixels: []pixel{{x: 32, y: 24, color: [3]float64{0, 0, 255}}}},
+		{name: "streams index", path: "filters:stream(1)/streams.mkv",
+			width: 32, height: 24, pixels: []pixel{{x: 16, y: 12, color: [3]float64{255, 255, 0}}}},
+		{name: "streams language", path: "filters:stream(fre)/streams.mkv",
+			width: 32, height: 24, pixels: []pixel{{x: 16, y: 12, color: [3]float64{255, 255, 0}}}},
+		{name: "streams title", path: "filters:stream(Commentary)/streams.mkv",
+			width: 32, height: 24, pixels: []pixel{{x: 16, y: 12, color: [3]float64{255, 255, 0}}}},
+		{name: "mkv chapter frame", path: "fit-in/100x100/filters:frame(chapter:2)/chapters.mkv",
+			width: 100, height: 75},
+		{name: "mkv chapter seek", path: "fit-in/100x100/filters:seek(chapter:1)/chapters.mkv",
+			width: 100, height: 75},
+		{name: "mkv chapter sprite", path: "fit-in/400x400/filters:seek(chapter:2):sprite(2,2,1s)/chapters.mkv",
+			width: 400, height: 300},
+		{name: "mkv chapter sprite vtt", path: "fit-in/400x400/filters:seek(chapter:1):sprite(2,2):vtt()/chapters.mkv"},
+		{name: "mkv chapter not found", path: "fit-in/100x100/filters:frame(chapter:3)/chapters.mkv", expectCode: 400},
+		{name: "mkv seek chapter not found", path: "fit-in/100x100/filters:seek(chapter:0)/chapters.mkv", expectCode: 400},
+		{name: "mkv meta chapters", path: "meta/chapters.mkv"},
+		{name: "mkv deinterlace on", path: "fit-in/100x100/filters:deinterlace(on)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv deinterlace off", path: "fit-in/100x100/filters:deinterlace(off)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv bitdepth 16", path: "fit-in/100x100/filters:format(png):bitdepth(16)/everybody-betray-me.mkv",
+			width: 100, height: 75, bands: 3, highDepth: true},
+		{name: "webm bitdepth 16 alpha", path: "fit-in/filters:format(png):bitdepth(16)/alpha-webm.webm",
+			width: 720, height: 576, bands: 4, highDepth: true},
 		{name: "mkv meta max_frames", path: "meta/filters:max_frames()/everybody-betray-me.mkv"},
 		{name: "mkv meta max_frames 6", path: "meta/filters:max_frames(6)/everybody-betray-me.mkv"},
 		{name: "mkv meta", path: "meta/everybody-betray-me.mkv"},
-		{name: "mp4", path: "200x100/schizo_0.mp4"},
-		{name: "mp4 orient 90", path: "220x100/schizo_90.mp4"},
-		{name: "mp4 orient 180", path: "200x100/schizo_180.mp4"},
-		{name: "mp4 orient 270", path: "200x100/schizo_270.mp4"},
+		{name: "mp4", path: "200x100/schizo_0.mp4",
+			width: 200, height: 100},
+		{name: "mp4 orient 90", path: "220x100/schizo_90.mp4",
+			width: 220, height: 100},
+		{name: "mp4 orient 180", path: "200x100/schizo_180.mp4",
+			width: 200, height: 100},
+		{name: "mp4 orient 270", path: "200x100/schizo_270.mp4",
+			width: 200, height: 100},
+		// quadrants of red, green, blue and white transposed by orientation 5
+		{name: "mp4 mirrored", path: "mirrored.mp4",
+			width: 32, height: 64, pixels: []pixel{
+				{x: 8, y: 16, color: [3]float64{255, 0, 0}},
+				{x: 24, y: 16, color: [3]float64{0, 0, 255}},
+				{x: 8, y: 48, color: [3]float64{0, 255, 0}},
+				{x: 24, y: 48, color: [3]float64{255, 255, 255}},
+			}},
+		{name: "mp4 mirrored resize", path: "fit-in/16x32/mirrored.mp4",
+			width: 16, height: 32, pixels: []pixel{
+				{x: 4, y: 8, color: [3]float64{255, 0, 0}},
+				{x: 12, y: 8, color: [3]float64{0, 0, 255}},
+				{x: 4, y: 24, color: [3]float64{0, 255, 0}},
+				{x: 12, y: 24, color: [3]float64{255, 255, 255}},
+			}},
 		{name: "image", path: "fit-in/100x100/demo.png"},
 		{name: "alpha", path: "fit-in/filters:format(png)/alpha-webm.webm"},
-		{name: "alpha frame duration", path: "500x/filters:frame(5s):format(png)/alpha-webm.webm"},
-		{name: "alpha frame position", path: "500x/filters:frame(0.5):format(png)/alpha-webm.webm"},
-		{name: "alpha seek duration", path: "500x/filters:seek(5s):format(png)/alpha-webm.webm"},
-		{name: "alpha seek position", path: "500x/filters:seek(0.5):format(png)/alpha-webm.webm"},
+		{name: "alpha frame duration", path: "500x/filters:frame(5s):format(png)/alpha-webm.webm",
+			width: 500, height: 400, bands: 4},
+		{name: "alpha frame position", path: "500x/filters:frame(0.5):format(png)/alpha-webm.webm",
+			width: 500, height: 400, bands: 4},
+		{name: "alpha seek duration", path: "500x/filters:seek(5s):format(png)/alpha-webm.webm",
+			width: 500, height: 400, bands: 4},
+		{name: "alpha seek position", path: "500x/filters:seek(0.5):format(png)/alpha-webm.webm",
+			width: 500, height: 400, bands: 4},
+		{name: "mkv preview", path: "fit-in/100x100/filters:preview(4)/everybody-betray-me.mkv",
+			width: 100, height: 75, pages: 4},
+		{name: "mkv preview interval gif", path: "fit-in/100x100/filters:seek(1s):preview(3,500ms):format(gif)/everybody-betray-me.mkv",
+			width: 100, height: 75, pages: 3},
+		{name: "mkv sprite", path: "fit-in/400x400/filters:sprite(4,3)/everybody-betray-me.mkv",
+			width: 400, height: 225},
+		{name: "mkv sprite interval", path: "fit-in/400x400/filters:seek(1s):sprite(3,2,1s)/everybody-betray-me.mkv",
+			width: 399, height: 200},
+		{name: "mp4 sprite orient 90", path: "fit-in/400x400/filters:sprite(3,3)/schizo_90.mp4",
+			width: 399, height: 300},
+		{name: "mkv sprite vtt", path: "fit-in/400x400/filters:sprite(4,3):vtt()/everybody-betray-me.mkv"},
+		{name: "mkv sprite vtt url", path: "filters:sprite(3,2,1s):vtt(sprite.jpg)/everybody-betray-me.mkv"},
+		{name: "mkv vtt without sprite", path: "fit-in/100x100/filters:vtt()/everybody-betray-me.mkv", expectCode: 400},
+		{name: "mkv bif", path: "filters:bif(2s)/everybody-betray-me.mkv",
+			bifFrames: 4, width: 320, height: 240},
+		{name: "mkv bif resize", path: "160x0/filters:bif()/everybody-betray-me.mkv",
+			bifFrames: 1, width: 160, height: 120},
 		{name: "corrupted", path: "fit-in/100x100/corrupt/everybody-betray-me.mkv", expectCode: 406},
+		{name: "with cover passthrough", path: "with_cover.mp3",
+			width: 1280, height: 720, bands: 4},
+		{name: "with cover front", path: "filters:cover(front)/with_cover.mp3",
+			width: 1280, height: 720, bands: 4},
+		{name: "with cover resize", path: "fit-in/100x100/filters:cover(1)/with_cover.mp3",
+			width: 100, height: 56},
+		{name: "with cover meta", path: "meta/with_cover.mp3"},
+		{name: "mkv cover without attachment", path: "fit-in/100x100/filters:cover()/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "mkv video with attachment", path: "cover.mkv",
+			width: 64, height: 48, pixels: []pixel{{x: 32, y: 24, color: [3]float64{0, 0, 255}}}},
+		{name: "mkv cover attachment", path: "filters:cover()/cover.mkv",
+			width: 32, height: 32, pixels: []pixel{{x: 8, y: 8, color: [3]float64{13, 238, 14}}}},
+		{name: "mp4 cover covr", path: "filters:cover()/cover.mp4",
+			width: 32, height: 32, pixels: []pixel{{x: 8, y: 8, color: [3]float64{13, 238, 14}}}},
+		{name: "mp4 cover covr resize", path: "fit-in/16x16/filters:cover()/cover.mp4",
+			width: 16, height: 16, pixels: []pixel{{x: 8, y: 8, color: [3]float64{13, 238, 14}}}},
 		{name: "no cover meta", path: "meta/no_cover.mp3"},
+		{name: "tags meta", path: "meta/tags.mp3"},
 		{name: "no cover 406", path: "fit-in/100x100/no_cover.mp3", expectCode: 406},
 	}, WithDebug(true), WithLogger(zap.NewExample()))
 	doGoldenTests(t, filepath.Join(testDataDir, "golden/result-fallback-image"), []test{
 		{name: "corrupted with fallback image", path: "fit-in/100x100/corrupt/everybody-betray-me.mkv", expectCode: 406},
 		{name: "corrupted with fallback image", path: "filters:seek(0.1)/no_cover.mp3", expectCode: 406},
+		{name: "chapter not found without fallback image", path: "fit-in/100x100/filters:frame(chapter:3)/chapters.mkv", expectCode: 400},
 	}, WithDebug(false), WithLogger(zap.NewExample()), WithFallbackImage("demo.png"))
+	doGoldenTests(t, filepath.Join(testDataDir, "golden/result-frame-scorer"), []test{
+		{name: "frame scorer", path: "fit-in/100x100/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "frame scorer scene", path: "fit-in/100x100/filters:scene()/everybody-betray-me.mkv",
+			width: 100, height: 75},
+		{name: "frame scorer specific frame", path: "fit-in/100x100/filters:frame(3)/everybody-betray-me.mkv",
+			width: 100, height: 75},
+	}, WithLogger(zap.NewExample()), WithFrameScorer(ffmpeg.FrameScorerFunc(func(frame *ffmpeg.Frame) (float64, bool) {
+		// prefers the latest frame, rejects the first 2 frames
+		return float64(frame.Index), frame.Index >= 2
+	})))
+	doGoldenTests(t, filepath.Join(testDataDir, "golden/result-fallback-image"), []test{
+		{name: "frame scorer rejected with fallback image", path: "fit-in/100x100/everybody-betray-me.mkv", expectCode: 406},
+	}, WithLogger(zap.NewExample()), WithFallbackImage("demo.png"),
+		WithFrameScorer(ffmpeg.FrameScorerFunc(func(frame *ffmpeg.Frame) (float64, bool) {
+			return 0, false
+		})))
 }
 
 func doGoldenTests(t *testing.T, resultDir string, tests []test, opts ...Option) {
@@ -128,6 +303,7 @@ func doGoldenTests(t *testing.T, resultDir string, tests []test, opts ...Option)
 				} else {
 					assert.Equal(t, tt.expectCode, w.Code)
 				}
+				assertDecoded(t, tt, w.Body.Bytes())
 				b := imagor.NewBlobFromBytes(w.Body.Bytes())
 				path := tt.path
 				if strings.HasPrefix(path, "meta/") {
@@ -159,6 +335,107 @@ func doGoldenTests(t *testing.T, resultDir string, tests []test, opts ...Option)
 
 }
 
+// assertDecoded decodes the response to assert the expected dimensions, pages, bands and pixels,
+// so that outputs are verified regardless of the golden
+func assertDecoded(t *testing.T, tt test, buf []byte) {
+	if tt.bifFrames > 0 {
+		require.Greater(t, len(buf), bifHeaderSize+(tt.bifFrames+1)*8)
+		require.Equal(t, bifMagic, buf[:8])
+		require.Equal(t, tt.bifFrames, int(binary.LittleEndian.Uint32(buf[12:])))
+		index := buf[bifHeaderSize:]
+		require.Equal(t, uint32(bifIndexEntryEnd), binary.LittleEndian.Uint32(index[tt.bifFrames*8:]))
+		require.Equal(t, len(buf), int(binary.LittleEndian.Uint32(index[tt.bifFrames*8+4:])))
+		buf = buf[binary.LittleEndian.Uint32(index[4:]):binary.LittleEndian.Uint32(index[12:])]
+	}
+	if tt.width == 0 && tt.height == 0 {
+		return
+	}
+	var opts *vips.LoadOptions
+	if tt.pages > 1 {
+		opts = &vips.LoadOptions{N: -1}
+	}
+	img, err := vips.NewImageFromBuffer(buf, opts)
+	require.NoError(t, err)
+	defer img.Close()
+	// off by one of the rounding of resize
+	assert.InDelta(t, tt.width, img.Width(), 1, "width")
+	assert.InDelta(t, tt.height, img.PageHeight(), 1, "height")
+	if tt.pages > 0 {
+		assert.Equal(t, tt.pages, img.Pages(), "pages")
+	}
+	if tt.bands > 0 {
+		assert.Equal(t, tt.bands, img.Bands(), "bands")
+	}
+	if tt.highDepth {
+		assert.Equal(t, vips.BandFormatUshort, img.BandFormat(), "band format")
+	}
+	for _, p := range tt.pixels {
+		color, err := img.Getpoint(p.x, p.y, nil)
+		require.NoError(t, err)
+		for i, c := range p.color {
+			assert.InDelta(t, c, color[i], 24, "pixel %d,%d", p.x, p.y)
+		}
+	}
+}
+
+func TestPassthrough(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "with_cover.mp3", expected: true},
+		{path: "filters:cover(front)/with_cover.mp3", expected: true},
+		{path: "fit-in/with_cover.mp3", expected: true},
+		{path: "100x100/with_cover.mp3"},
+		{path: "-0x0/with_cover.mp3"},
+		{path: "10x10:100x100/with_cover.mp3"},
+		{path: "filters:format(webp)/with_cover.mp3"},
+		{path: "filters:cover(back):grayscale()/with_cover.mp3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, passthrough(imagorpath.Parse(tt.path)))
+		})
+	}
+}
+
+func TestExportSize(t *testing.T) {
+	meta := orientedMeta(1920, 1080, 1)
+	tests := []struct {
+		path   string
+		meta   *ffmpeg.Metadata
+		width  int
+		height int
+		ok     bool
+	}{
+		{path: "fit-in/100x100/video.mp4", meta: meta, width: 100, height: 56, ok: true},
+		{path: "200x100/video.mp4", meta: meta, width: 200, height: 113, ok: true},
+		{path: "0x270/video.mp4", meta: meta, width: 480, height: 270, ok: true},
+		{path: "fit-in/100x100/video.mp4", width: 56, height: 100, ok: true,
+			meta: orientedMeta(1080, 1920, 1)},
+		{path: "fit-in/100x100/video.mp4", width: 100, height: 56, ok: true,
+			meta: orientedMeta(1920, 1080, 6)},
+		{path: "fit-in/100x100/video.mp4", width: 100, height: 56, ok: true,
+			meta: &ffmpeg.Metadata{Width: 1440, Height: 1080, DisplayWidth: 1920, DisplayHeight: 1080, Orientation: 1}},
+		{path: "video.mp4", meta: meta},
+		{path: "fit-in/4000x4000/video.mp4", meta: meta},
+		{path: "10x10:500x500/200x100/video.mp4", meta: meta},
+		{path: "trim/200x100/video.mp4", meta: meta},
+		{path: "200x100/filters:autocrop()/video.mp4", meta: meta},
+		{path: "200x100/filters:sprite(3,3)/video.mp4", meta: meta},
+		{path: "fit-in/100x100/filters:preview(4)/video.mp4", meta: meta, width: 100, height: 56, ok: true},
+		{path: "200x100/video.mp3", meta: &ffmpeg.Metadata{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			width, height, ok := exportSize(imagorpath.Parse(tt.path), tt.meta)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.width, width)
+			assert.Equal(t, tt.height, height)
+		})
+	}
+}
+
 // TestProcessorRawBypass verifies that camera RAW formats are forwarded to the next
 // processor (vipsprocessor) rather than being decoded by ffmpeg.
 // CR3 (Canon RAW 3) is an ISO BMFF container that mimetype may misdetect as video.
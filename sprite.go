@@ -0,0 +1,109 @@
+package imagorvideo
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+)
+
+const (
+	maxSpriteFrames = 100
+	// maxSpriteBytes max byte size of the sprite sheet, as of RGBA tiles
+	maxSpriteBytes = 64 << 20
+)
+
+// spriteGrid sprite sheet grid of cols x rows frames
+type spriteGrid struct {
+	Cols, Rows int
+	Interval   time.Duration
+}
+
+// parseSpriteGrid parses sprite filter args cols,rows[,interval]
+func parseSpriteGrid(args string) (g spriteGrid, ok bool) {
+	arr := strings.Split(args, ",")
+	if len(arr) < 2 {
+		return
+	}
+	g.Cols, _ = strconv.Atoi(strings.TrimSpace(arr[0]))
+	g.Rows, _ = strconv.Atoi(strings.TrimSpace(arr[1]))
+	if len(arr) > 2 {
+		g.Interval, _ = time.ParseDuration(strings.TrimSpace(arr[2]))
+	}
+	// bound each dimension before multiplying so that the product cannot overflow
+	if g.Cols < 1 || g.Rows < 1 || g.Cols > maxSpriteFrames || g.Rows > maxSpriteFrames ||
+		g.Cols*g.Rows > maxSpriteFrames {
+		return
+	}
+	return g, true
+}
+
+// Durations sample points of each tile
+func (g spriteGrid) Durations(start, end time.Duration) []time.Duration {
+	return sampleDurations(g.Cols*g.Rows, start, end, g.Interval)
+}
+
+// tileSize dimensions of the exported frame of each tile, before orientation.
+// Tiles are downscaled to the resize of the request applied to the whole sheet,
+// then further down if the sheet would exceed maxSpriteBytes
+func (g spriteGrid) tileSize(params imagorpath.Params, meta *ffmpeg.Metadata) (width, height int) {
+	width, height = meta.FrameSize()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	scale := 1.0
+	if !imagorpath.HasCrop(params) && !params.Trim && !params.Stretch &&
+		!params.AdaptiveFitIn && !params.FullFitIn {
+		w, h := width, height
+		if meta.Orientation >= 5 {
+			w, h = h, w
+		}
+		if s, _, _ := resizeScale(params, g.Cols*w, g.Rows*h); s > 0 && s < 1 {
+			scale = s
+		}
+	}
+	if size := float64(g.Cols*g.Rows*width*height*4) * scale * scale; size > maxSpriteBytes {
+		scale *= math.Sqrt(maxSpriteBytes / size)
+	}
+	if scale >= 1 {
+		return
+	}
+	width = max(int(math.Round(float64(width)*scale)), 1)
+	height = max(int(math.Round(float64(height)*scale)), 1)
+	return
+}
+
+// newSpriteBlob exports frames at durations in tile size and tiles them into sprite sheet,
+// with orientation applied to each tile
+func newSpriteBlob(
+	av *ffmpeg.AVContext, meta *ffmpeg.Metadata, params imagorpath.Params,
+	g spriteGrid, durations []time.Duration, bands int,
+) (*imagor.Blob, error) {
+	tileWidth, tileHeight := g.tileSize(params, meta)
+	if frameWidth, frameHeight := meta.FrameSize(); tileWidth != frameWidth || tileHeight != frameHeight {
+		if err := av.SetExportSize(tileWidth, tileHeight); err != nil {
+			return nil, err
+		}
+	}
+	bufs, err := av.ExportFrames(durations, bands)
+	if err != nil {
+		return nil, err
+	}
+	var width, height = tileWidth, tileHeight
+	for i, buf := range bufs {
+		bufs[i], width, height = orientBuffer(buf, tileWidth, tileHeight, bands, meta.Orientation)
+	}
+	stride := g.Cols * width * bands
+	sheet := make([]byte, stride*g.Rows*height)
+	for i, buf := range bufs {
+		left, top := i%g.Cols*width, i/g.Cols*height
+		for y := 0; y < height; y++ {
+			copy(sheet[(top+y)*stride+left*bands:], buf[y*width*bands:(y+1)*width*bands])
+		}
+	}
+	return imagor.NewBlobFromMemory(sheet, g.Cols*width, g.Rows*height, bands), nil
+}
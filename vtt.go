@@ -0,0 +1,111 @@
+package imagorvideo
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/cshum/imagorvideo/ffmpeg"
+)
+
+var (
+	errVTTWithoutSprite = imagor.NewError("vtt() requires sprite(cols,rows[,interval])", http.StatusBadRequest)
+	errVTTWithoutURL    = imagor.NewError("vtt(url) requires url of the sprite sheet for signed request", http.StatusBadRequest)
+)
+
+// spritePath absolute imagor endpoint of the sprite sheet that matches the WebVTT request,
+// unsafe if the request is unsafe, otherwise signed by signer.
+// Empty if the endpoint cannot be signed
+func spritePath(params imagorpath.Params, signer imagorpath.Signer) string {
+	var filters imagorpath.Filters
+	for _, filter := range params.Filters {
+		if filter.Name != "vtt" {
+			filters = append(filters, filter)
+		}
+	}
+	params.Filters = filters
+	switch {
+	case params.Unsafe:
+		return "/" + imagorpath.GenerateUnsafe(params)
+	case signer != nil:
+		return "/" + imagorpath.Generate(params, signer)
+	}
+	return ""
+}
+
+// resizeScale scale factor and crop offset of imagor resize
+// for image of the specified dimensions
+func resizeScale(params imagorpath.Params, width, height int) (scale, left, top float64) {
+	w, h := float64(params.Width), float64(params.Height)
+	ww, hh := float64(width), float64(height)
+	switch {
+	case w == 0 && h == 0:
+		return 1, 0, 0
+	case params.FitIn:
+		scale = math.Inf(1)
+		if w > 0 {
+			scale = w / ww
+		}
+		if h > 0 {
+			scale = math.Min(scale, h/hh)
+		}
+		if !imagorpath.HasFilter(params, "upscale") {
+			scale = math.Min(scale, 1)
+		}
+		return
+	case w == 0:
+		return h / hh, 0, 0
+	case h == 0:
+		return w / ww, 0, 0
+	default:
+		// fill then crop at center
+		scale = math.Max(w/ww, h/hh)
+		return scale, (ww*scale - w) / 2, (hh*scale - h) / 2
+	}
+}
+
+// newSpriteVTTBlob creates WebVTT thumbnail track that maps sample durations
+// to the corresponding sprite sheet tile regions, with the last cue until end
+func newSpriteVTTBlob(
+	url string, params imagorpath.Params, meta *ffmpeg.Metadata, g spriteGrid, durations []time.Duration, end time.Duration,
+) *imagor.Blob {
+	// regions of tiles as of the sprite sheet, then scaled by the resize of the sheet
+	width, height := g.tileSize(params, meta)
+	if meta.Orientation >= 5 {
+		width, height = height, width
+	}
+	scale, left, top := resizeScale(params, g.Cols*width, g.Rows*height)
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for i, ts := range durations {
+		var next time.Duration
+		if i+1 < len(durations) {
+			next = durations[i+1]
+		} else {
+			next = ts + g.Interval
+			if end > ts && (next <= ts || next > end) {
+				next = end
+			}
+			if next <= ts {
+				next = ts + time.Second
+			}
+		}
+		x := math.Round(float64(i%g.Cols*width)*scale - left)
+		y := math.Round(float64(i/g.Cols*height)*scale - top)
+		_, _ = fmt.Fprintf(&sb, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			formatVTTDuration(ts), formatVTTDuration(next), url,
+			int(x), int(y), int(math.Round(float64(width)*scale)), int(math.Round(float64(height)*scale)))
+	}
+	blob := imagor.NewBlobFromBytes([]byte(sb.String()))
+	blob.SetContentType("text/vtt")
+	return blob
+}
+
+func formatVTTDuration(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
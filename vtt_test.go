@@ -0,0 +1,133 @@
+package imagorvideo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpriteVTT(t *testing.T) {
+	meta := orientedMeta(640, 360, 1)
+	meta.Duration = 10000
+	params := imagorpath.Parse("unsafe/fit-in/640x640/filters:sprite(2,2):vtt()/foo.mp4")
+	g, ok := parseSpriteGrid("2,2")
+	require.True(t, ok)
+	assert.Equal(t, "/unsafe/fit-in/640x640/filters:sprite(2,2)/foo.mp4", spritePath(params, nil))
+	blob := newSpriteVTTBlob("sprite.jpg", params, meta, g, g.Durations(0, metaDuration(meta)), metaDuration(meta))
+	assert.Equal(t, "text/vtt", blob.ContentType())
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:02.500
+sprite.jpg#xywh=0,0,320,180
+
+00:00:02.500 --> 00:00:05.000
+sprite.jpg#xywh=320,0,320,180
+
+00:00:05.000 --> 00:00:07.500
+sprite.jpg#xywh=0,180,320,180
+
+00:00:07.500 --> 00:00:10.000
+sprite.jpg#xywh=320,180,320,180
+`, string(buf))
+}
+
+func TestSpritePath(t *testing.T) {
+	signer := imagorpath.NewDefaultSigner("1234")
+	params := imagorpath.Parse("filters:sprite(3,1):vtt()/foo.mp4")
+	assert.Empty(t, spritePath(params, nil))
+	path := spritePath(params, signer)
+	assert.Equal(t, "/"+imagorpath.Generate(imagorpath.Parse("filters:sprite(3,1)/foo.mp4"), signer), path)
+	assert.Equal(t, "filters:sprite(3,1)/foo.mp4", imagorpath.Parse(path).Path)
+	assert.False(t, imagorpath.Parse(path).Unsafe)
+}
+
+func TestSpriteVTTInterval(t *testing.T) {
+	meta := orientedMeta(200, 100, 6)
+	meta.Duration = 3500
+	params := imagorpath.Parse("filters:sprite(3,1,1s):vtt()/foo.mp4")
+	g, ok := parseSpriteGrid("3,1,1s")
+	require.True(t, ok)
+	blob := newSpriteVTTBlob("sprite.jpg", params, meta, g, g.Durations(2*time.Second, metaDuration(meta)), metaDuration(meta))
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, `WEBVTT
+
+00:00:02.000 --> 00:00:03.000
+sprite.jpg#xywh=0,0,100,200
+
+00:00:03.000 --> 00:00:03.500
+sprite.jpg#xywh=100,0,100,200
+`, string(buf))
+}
+
+func TestParseSpriteGrid(t *testing.T) {
+	for args, ok := range map[string]bool{
+		"2,2":                   true,
+		"10,10,1s":              true,
+		"0,2":                   false,
+		"2":                     false,
+		"101,1":                 false,
+		"11,10":                 false,
+		"4294967296,4294967296": false,
+		"-4294967296,-1":        false,
+	} {
+		_, valid := parseSpriteGrid(args)
+		assert.Equal(t, ok, valid, args)
+	}
+	assert.Nil(t, spriteGrid{}.Durations(0, time.Second))
+}
+
+func TestSpriteTileSize(t *testing.T) {
+	g, ok := parseSpriteGrid("2,2")
+	require.True(t, ok)
+	meta := orientedMeta(640, 360, 6)
+	width, height := g.tileSize(imagorpath.Parse("fit-in/360x640/foo.mp4"), meta)
+	assert.Equal(t, 320, width)
+	assert.Equal(t, 180, height)
+	width, height = g.tileSize(imagorpath.Parse("fit-in/2000x2000/foo.mp4"), meta)
+	assert.Equal(t, 640, width)
+	assert.Equal(t, 360, height)
+
+	g, ok = parseSpriteGrid("10,10")
+	require.True(t, ok)
+	meta = orientedMeta(3840, 2160, 1)
+	width, height = g.tileSize(imagorpath.Parse("foo.mp4"), meta)
+	assert.LessOrEqual(t, g.Cols*g.Rows*width*height*4, maxSpriteBytes)
+	assert.InDelta(t, 16.0/9, float64(width)/float64(height), 0.01)
+}
+
+func TestPreviewSize(t *testing.T) {
+	width, height := previewSize(160, 90, 50, 3)
+	assert.Equal(t, 160, width)
+	assert.Equal(t, 90, height)
+
+	width, height = previewSize(3840, 2160, 50, 3)
+	assert.LessOrEqual(t, 50*width*height*3, maxPreviewBytes)
+	assert.InDelta(t, 16.0/9, float64(width)/float64(height), 0.01)
+}
+
+func TestResizeScale(t *testing.T) {
+	for _, tt := range []struct {
+		path        string
+		scale       float64
+		left, right float64
+	}{
+		{path: "foo.mp4", scale: 1},
+		{path: "400x0/foo.mp4", scale: 0.5},
+		{path: "0x200/foo.mp4", scale: 0.5},
+		{path: "fit-in/400x100/foo.mp4", scale: 0.25},
+		{path: "fit-in/1600x1600/foo.mp4", scale: 1},
+		{path: "fit-in/1600x1600/filters:upscale()/foo.mp4", scale: 2},
+		{path: "200x200/foo.mp4", scale: 0.5, left: 100},
+	} {
+		scale, left, top := resizeScale(imagorpath.Parse(tt.path), 800, 400)
+		assert.Equal(t, tt.scale, scale, tt.path)
+		assert.Equal(t, tt.left, left, tt.path)
+		assert.Equal(t, tt.right, top, tt.path)
+	}
+}